@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped, by requests made while the circuit breaker is open. Check for it with
+// errors.Is(err, client.ErrCircuitOpen).
+var ErrCircuitOpen = errors.New("flyte api circuit breaker is open")
+
+// The defaults for the circuit breaker settings that are not set.
+const (
+	defaultOpenInterval   = 30 * time.Second
+	defaultHalfOpenProbes = 1
+)
+
+// CircuitState is the state of the circuit breaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests are sent to flyte-api
+	CircuitHalfOpen                     // a limited number of probe requests are sent to find out if flyte-api has recovered
+	CircuitOpen                         // requests fail fast with ErrCircuitOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops the client sending requests to flyte-api while it is failing. After FailureThreshold
+// consecutive failures - connection errors, timeouts or 5xx responses - the circuit opens and requests fail fast with
+// ErrCircuitOpen. Once OpenInterval has passed, HalfOpenProbes requests are let through. The circuit closes if they
+// all succeed, and opens again if any fails.
+type CircuitBreaker struct {
+	FailureThreshold int           // the consecutive failures that open the circuit. 0 disables the circuit breaker
+	OpenInterval     time.Duration // how long requests fail fast before probing flyte-api. Defaults to 30 seconds
+	HalfOpenProbes   int           // the probe requests that must succeed to close the circuit. Defaults to 1
+}
+
+// CircuitStateReporter is implemented by clients with a circuit breaker, so packs can report its state.
+type CircuitStateReporter interface {
+	// CircuitState returns the current state, and false if the circuit breaker is disabled.
+	CircuitState() (CircuitState, bool)
+}
+
+// the circuit breaker of a client. A nil breaker is disabled and lets every request through
+type breaker struct {
+	settings CircuitBreaker
+	now      func() time.Time
+	onChange func(CircuitState) // called with the lock held when the state changes
+
+	mu         sync.Mutex
+	state      CircuitState
+	generation int // incremented on each change of state, so results of requests from an earlier state are ignored
+	failures   int // consecutive failures while closed
+	openedAt   time.Time
+	probes     int // probes in flight or succeeded while half-open
+	successes  int // probes succeeded while half-open
+}
+
+// creates the breaker, or nil if the circuit breaker is disabled
+func newBreaker(settings CircuitBreaker, onChange func(CircuitState)) *breaker {
+	if settings.FailureThreshold <= 0 {
+		return nil
+	}
+	if settings.OpenInterval <= 0 {
+		settings.OpenInterval = defaultOpenInterval
+	}
+	if settings.HalfOpenProbes <= 0 {
+		settings.HalfOpenProbes = defaultHalfOpenProbes
+	}
+	if onChange == nil {
+		onChange = func(CircuitState) {}
+	}
+	return &breaker{settings: settings, now: time.Now, onChange: onChange}
+}
+
+// returns ErrCircuitOpen if the request must fail fast. Otherwise the request is let through, and its result must be
+// passed to record or release with the generation returned
+func (b *breaker) allow() (int, error) {
+	if b == nil {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if b.now().Sub(b.openedAt) < b.settings.OpenInterval {
+			return b.generation, ErrCircuitOpen
+		}
+		b.setState(CircuitHalfOpen)
+	}
+	if b.state == CircuitHalfOpen {
+		if b.probes >= b.settings.HalfOpenProbes {
+			return b.generation, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// records whether a request let through by allow failed
+func (b *breaker) record(generation int, failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case CircuitClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			b.setState(CircuitOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.setState(CircuitClosed)
+		}
+	}
+}
+
+// releases a request let through by allow without recording a result, e.g. when the caller cancelled it
+func (b *breaker) release(generation int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation && b.state == CircuitHalfOpen {
+		b.probes--
+	}
+}
+
+// the current state, and false if the breaker is disabled
+func (b *breaker) currentState() (CircuitState, bool) {
+	if b == nil {
+		return CircuitClosed, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, true
+}
+
+// changes the state, resetting the counts. Must be called with the lock held
+func (b *breaker) setState(s CircuitState) {
+	b.state = s
+	b.generation++
+	b.failures, b.probes, b.successes = 0, 0, 0
+	if s == CircuitOpen {
+		b.openedAt = b.now()
+	}
+	b.onChange(s)
+}
+
+// closes the circuit, e.g. when the client switches to another flyte-api endpoint
+func (b *breaker) reset() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		b.setState(CircuitClosed)
+	}
+	b.failures = 0
+}
@@ -0,0 +1,219 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBreaker_ShouldOpenAfterConsecutiveFailures(t *testing.T) {
+	// given
+	b, _ := newTestBreaker(CircuitBreaker{FailureThreshold: 3})
+
+	// when a success resets the failure count, then three failures in a row
+	fail(t, b)
+	succeed(t, b)
+	fail(t, b)
+	fail(t, b)
+	assertState(t, b, CircuitClosed)
+	fail(t, b)
+
+	// then requests fail fast
+	assertState(t, b, CircuitOpen)
+	_, err := b.allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+}
+
+func TestBreaker_ShouldProbeOnceTheOpenIntervalHasPassed(t *testing.T) {
+	// given an open circuit
+	b, clock := newTestBreaker(CircuitBreaker{FailureThreshold: 1, OpenInterval: time.Minute, HalfOpenProbes: 2})
+	fail(t, b)
+
+	// when the open interval passes
+	clock.advance(time.Minute)
+
+	// then only the probes are let through
+	first, err := b.allow()
+	require.NoError(t, err)
+	second, err := b.allow()
+	require.NoError(t, err)
+	_, err = b.allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+	assertState(t, b, CircuitHalfOpen)
+
+	// and the circuit closes once they all succeed
+	b.record(first, false)
+	assertState(t, b, CircuitHalfOpen)
+	b.record(second, false)
+	assertState(t, b, CircuitClosed)
+}
+
+func TestBreaker_ShouldOpenAgain_WhenAProbeFails(t *testing.T) {
+	// given a half-open circuit
+	b, clock := newTestBreaker(CircuitBreaker{FailureThreshold: 1, OpenInterval: time.Minute})
+	fail(t, b)
+	clock.advance(time.Minute)
+
+	// when the probe fails
+	fail(t, b)
+
+	// then the circuit is open for another interval
+	assertState(t, b, CircuitOpen)
+	clock.advance(time.Minute - time.Second)
+	_, err := b.allow()
+	assert.Equal(t, ErrCircuitOpen, err)
+}
+
+func TestBreaker_ShouldIgnoreResultsOfRequestsFromAnEarlierState(t *testing.T) {
+	// given a request let through while closed that is still in flight when the circuit opens
+	b, clock := newTestBreaker(CircuitBreaker{FailureThreshold: 1, OpenInterval: time.Minute})
+	slow, err := b.allow()
+	require.NoError(t, err)
+	fail(t, b)
+	clock.advance(time.Minute)
+	probe, err := b.allow()
+	require.NoError(t, err)
+
+	// when the slow request succeeds
+	b.record(slow, false)
+
+	// then it is not taken as the result of the probe
+	assertState(t, b, CircuitHalfOpen)
+	b.record(probe, false)
+	assertState(t, b, CircuitClosed)
+}
+
+func TestBreaker_ShouldLetAnotherProbeThrough_WhenAProbeIsReleased(t *testing.T) {
+	// given a half-open circuit with its probe in flight
+	b, clock := newTestBreaker(CircuitBreaker{FailureThreshold: 1, OpenInterval: time.Minute})
+	fail(t, b)
+	clock.advance(time.Minute)
+	probe, _ := b.allow()
+
+	// when the probe is cancelled
+	b.release(probe)
+
+	// then another probe is let through
+	_, err := b.allow()
+	assert.NoError(t, err)
+}
+
+func TestBreaker_ShouldBeDisabled_WhenThereIsNoFailureThreshold(t *testing.T) {
+	b := newBreaker(CircuitBreaker{}, nil)
+
+	assert.Nil(t, b)
+	_, err := b.allow()
+	assert.NoError(t, err)
+	_, enabled := b.currentState()
+	assert.False(t, enabled)
+}
+
+func Test_PostEvent_ShouldFailFast_WhenTheCircuitIsOpen(t *testing.T) {
+	// given flyte-api is failing
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// and a client that opens the circuit after two failures
+	m := metrics.NewPrometheus()
+	c := newTestClient(ts.URL, t)
+	c.metrics = m
+	c.breaker = newBreaker(CircuitBreaker{FailureThreshold: 2}, circuitChanged(m, nil))
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when
+	for i := 0; i < 3; i++ {
+		c.PostEvent(Event{Name: "Deployed"})
+	}
+	err := c.PostEvent(Event{Name: "Deployed"})
+
+	// then the requests after the circuit opened were not sent
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "got %v", err)
+	assert.Equal(t, 2, requests)
+	state, enabled := c.CircuitState()
+	assert.True(t, enabled)
+	assert.Equal(t, CircuitOpen, state)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "flyte_client_circuit_state 2\n")
+	assert.Contains(t, rec.Body.String(), `flyte_client_circuit_transitions_total{state="open"} 1`)
+	assert.Contains(t, rec.Body.String(), `flyte_client_circuit_rejected_total{endpoint="postEvent"} 2`)
+}
+
+func Test_TakeAction_ShouldNotCountCancelledRequestsAsFailures(t *testing.T) {
+	// given
+	ts := mockServer(http.StatusNoContent, "")
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.breaker = newBreaker(CircuitBreaker{FailureThreshold: 1}, nil)
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take/action/url")
+
+	// when the caller cancels the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.TakeActionContext(ctx)
+
+	// then
+	require.Error(t, err)
+	state, _ := c.CircuitState()
+	assert.Equal(t, CircuitClosed, state)
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(settings CircuitBreaker) (*breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := newBreaker(settings, nil)
+	b.now = func() time.Time { return clock.now }
+	return b, clock
+}
+
+func fail(t *testing.T, b *breaker) {
+	generation, err := b.allow()
+	require.NoError(t, err)
+	b.record(generation, true)
+}
+
+func succeed(t *testing.T, b *breaker) {
+	generation, err := b.allow()
+	require.NoError(t, err)
+	b.record(generation, false)
+}
+
+func assertState(t *testing.T, b *breaker, want CircuitState) {
+	state, _ := b.currentState()
+	assert.Equal(t, want, state, "got %s, want %s", state, want)
+}
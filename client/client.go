@@ -19,16 +19,16 @@ limitations under the License.
 package client
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/ExpediaGroup/flyte-client/config"
-	"github.com/rs/zerolog/log"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
 	"net/http"
 	"net/url"
 	"path"
-	"strings"
 	"time"
 )
 
@@ -45,38 +45,69 @@ type Client interface {
 	GetFlyteHealthCheckURL() (*url.URL, error)
 }
 
+// ContextClient is implemented by clients that send the trace context of ctx to the flyte server with their requests.
+// Packs use these methods when the client implements them.
+type ContextClient interface {
+	// PostEventContext posts events to the flyte server.
+	PostEventContext(ctx context.Context, event Event) error
+	// TakeActionContext takes the next action the pack should process. If no action is available, nil is returned.
+	TakeActionContext(ctx context.Context) (*Action, error)
+	// CompleteActionContext posts the action result to the flyte server.
+	CompleteActionContext(ctx context.Context, action Action, event Event) error
+}
+
 type client struct {
-	eventsURL     *url.URL
-	baseURL       *url.URL
-	takeActionURL *url.URL
-	apiLinks      map[string][]Link
+	*links        // of the flyte api endpoint in use
 	httpClient    *http.Client
+	metrics       metrics.Metrics
+	logger        logging.Logger
+	redactor      redact.Redactor
+	breaker       *breaker
+	failover      *failover
+	compressor    *compressor
+	encoding      EventEncoding             // how events are sent, negotiated each time the pack is registered if it is EncodingNegotiate
+	idempotency   bool                      // whether flyte-api is known to support the Idempotency-Key header, without advertising it
+	retries       map[Operation]RetryPolicy // by operation, the operations not in the map are not retried
+	maxBodySize   int64                     // the largest response body read, 0 means no limit
+	linkTTL       time.Duration             // how long links are used before they are discovered again, 0 means until they are not found
 }
 
 const (
 	ApiVersion        = "v1"
 )
-var (
-	flyteApiRetryWait = 3 * time.Second
-)
 
 
 // To create a new client, please provide the url of the flyte server and the timeout.
 // timeout specifies a time limit for requests made by this
 // client. A timeout of zero means no timeout.
 // Insecure mode is either true or false
-func NewClient(rootURL *url.URL, timeout time.Duration) Client {
-	return newClient(rootURL, timeout, false)
+func NewClient(rootURL *url.URL, timeout time.Duration, opts ...Option) Client {
+	return newClient(rootURL, timeout, false, opts)
 }
 
-func NewInsecureClient(rootURL *url.URL, timeout time.Duration) Client {
-	return newClient(rootURL, timeout, true)
+func NewInsecureClient(rootURL *url.URL, timeout time.Duration, opts ...Option) Client {
+	return newClient(rootURL, timeout, true, opts)
 }
 
-func newClient(rootURL *url.URL, timeout time.Duration, isInsecure bool) Client {
+func newClient(rootURL *url.URL, timeout time.Duration, isInsecure bool, opts []Option) Client {
+	o := newOptions(opts)
 	client := &client{
-		baseURL:    getBaseURL(*rootURL),
-		httpClient: newHttpClient(timeout, isInsecure),
+		links:       &links{baseURL: getBaseURL(*rootURL)},
+		httpClient:  httpClientFor(timeout, isInsecure, o),
+		metrics:     o.metrics,
+		logger:      o.logger,
+		redactor:    o.redactor,
+		breaker:     newBreaker(o.breaker, circuitChanged(o.metrics, o.logger)),
+		failover:    newFailover(rootURL, o.failover),
+		retries:     o.retries,
+		encoding:    o.encoding,
+		idempotency: o.idempotency,
+		compressor:  newCompressor(o.compression),
+		maxBodySize: o.maxResponseSize,
+		linkTTL:     o.linkTTL,
+	}
+	if client.breaker != nil {
+		metrics.SetGauge(metrics.OrNop(o.metrics), metrics.ClientCircuitState, nil, float64(CircuitClosed))
 	}
 	client.getApiLinks()
 	return client
@@ -88,18 +119,29 @@ func getBaseURL(u url.URL) *url.URL {
 	return &u
 }
 
-func newHttpClient(timeout time.Duration, isInsecure bool) *http.Client {
+func newHttpClient(timeout time.Duration, isInsecure bool, opts ...Option) *http.Client {
+	return httpClientFor(timeout, isInsecure, newOptions(opts))
+}
+
+func httpClientFor(timeout time.Duration, isInsecure bool, o options) *http.Client {
+
+	tlsConfig := &tls.Config{}
+	if o.tlsConfig != nil {
+		tlsConfig = o.tlsConfig.Clone()
+	}
+	if isInsecure {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
 	httpClient := &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: isInsecure},
-		},
+		Timeout:   timeout,
+		Transport: o.transport.httpTransport(tlsConfig, o.proxy),
 	}
 
 	// this decorates the client transport with the jwt header
-	if config.GetJWT() != "" {
+	if o.jwt != "" {
 		t := transportWithHeader{Header: make(http.Header), rt: httpClient.Transport}
-		t.Set("Authorization", fmt.Sprintf("Bearer %s", config.GetJWT()))
+		t.Set("Authorization", fmt.Sprintf("Bearer %s", o.jwt))
 		httpClient.Transport = t
 	}
 	return httpClient
@@ -117,144 +159,264 @@ func (h transportWithHeader) RoundTrip(req *http.Request) (*http.Response, error
 	return h.rt.RoundTrip(req)
 }
 
-// getApiLinks retrieves links from the flyte api server that are useful to the client such as packs url and health url and so on
-func (c *client) getApiLinks() {
-	var links map[string][]Link
-
-	if err := c.getStruct(c.baseURL, &links); err != nil {
-		log.Err(err).Msg("cannot get api links")
-		time.Sleep(flyteApiRetryWait)
-		c.getApiLinks()
-		return
+// getApiLinks retrieves links from the flyte api server that are useful to the client such as packs url and health url and so on.
+// Each request is retried as the OperationAPILinks retry policy allows, and any other failure, such as a response that
+// cannot be read, is retried with the backoff of the same policy, up to its MaxAttempts. Without a policy for
+// OperationAPILinks, DefaultRetryPolicy is used. If the links still cannot be retrieved they are retrieved again when
+// the pack is created
+func (c *client) getApiLinks() error {
+	policy, ok := c.retries[OperationAPILinks]
+	if !ok {
+		policy = DefaultRetryPolicy()
+	}
+	for attempt := 1; ; attempt++ {
+		var links map[string][]Link
+		err := c.getStruct(context.Background(), OperationAPILinks, c.base(), &links)
+		if err == nil {
+			c.setAPI(links)
+			return nil
+		}
+		if attempt >= policy.MaxAttempts {
+			logging.OrDefault(c.logger).Error("cannot get api links", logging.KeyError, err)
+			return err
+		}
+		wait := policy.Backoff(attempt)
+		logging.OrDefault(c.logger).Warn(fmt.Sprintf("cannot get api links, retrying in %v", wait), logging.KeyError, err)
+		time.Sleep(wait)
 	}
-	c.apiLinks = links
 }
 
 // CreatePack is responsible for posting your pack to the flyte server, making it available to be used by the flows.
+// The pack is registered again with each endpoint the client switches to, and when the links are discovered again.
 func (c *client) CreatePack(pack Pack) error {
 
-	var err error
-	if err = c.registerPack(&pack); err != nil {
+	def := pack
+	packsURL, err := c.registerPack(&pack)
+	if err != nil {
 		return err
 	}
 
-	if c.eventsURL, err = findURLByRel(pack.Links, "event"); err != nil {
+	packLinks := newLinkRegistry(packsURL, pack.Links, templateVars(pack.Name))
+	eventsURL, err := packLinks.find("event")
+	if err != nil {
 		return err
 	}
 
-	if c.takeActionURL, err = findURLByRel(pack.Links, "takeAction"); err != nil {
+	takeActionURL, err := packLinks.find("takeAction")
+	if err != nil {
 		return err
 	}
 
+	encoding := c.encoding
+	if encoding == EncodingNegotiate {
+		encoding = negotiateEncoding(pack.Links, c.api()["links"])
+	}
+	c.setPack(eventsURL, takeActionURL, def, encoding)
 	return nil
 }
 
-// registerPack posts the pack, and handles the response
-func (c *client) registerPack(pack *Pack) error {
+// registerPack posts the pack, and handles the response. Returns the url the pack was posted to, which relative
+// links of the pack are resolved against
+func (c *client) registerPack(pack *Pack) (*url.URL, error) {
 	packsURL, err := c.getPacksURL()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	resp, err := c.post(packsURL, pack)
+	resp, err := c.post(context.Background(), OperationCreatePack, packsURL, pack)
 	if err != nil {
-		return fmt.Errorf("error posting pack %+v to %s: %v", pack, packsURL.String(), err)
+		return nil, fmt.Errorf("error posting pack %s to %s: %w", c.redact(pack), packsURL.String(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("pack not created, response was: %+v", resp)
+		return nil, fmt.Errorf("pack not created, response was: %s", describeResponse(resp))
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(pack)
+	err = c.decode(resp, pack)
 	if err != nil {
-		return fmt.Errorf("could not deserialise response: %s", err)
+		return nil, fmt.Errorf("could not deserialise response: %w", err)
 	}
 
-	return nil
+	return packsURL, nil
 }
 
 // getPacksURL finds out where packs should be posted to
 func (c *client) getPacksURL() (*url.URL, error) {
-	return findURLByRel(c.apiLinks["links"], "pack/listPacks")
+	if c.api() == nil {
+		if err := c.getApiLinks(); err != nil {
+			return nil, fmt.Errorf("cannot get api links: %w", err)
+		}
+	}
+	return c.findURLByRel(c.base(), c.api()["links"], "pack/listPacks")
 }
 
 // GetFlyteHealthCheckURL finds out the flyte healthcheck url
 func (c *client) GetFlyteHealthCheckURL() (*url.URL, error) {
-	return findURLByRel(c.apiLinks["links"], "info/health")
+	return c.findURLByRel(c.base(), c.api()["links"], "info/health")
 }
 
 // PostEvent posts events to the flyte server
 func (c client) PostEvent(event Event) error {
-	event.CreatedAt = time.Now().UTC()
-	if c.eventsURL == nil {
+	return c.PostEventContext(context.Background(), event)
+}
+
+// PostEventContext posts events to the flyte server, with the trace context of ctx. The event ID is sent as the
+// Idempotency-Key header, and stays the same if the request is retried. If the events url is not found, the links are
+// discovered again and the event is posted again
+func (c client) PostEventContext(ctx context.Context, event Event) error {
+	event = c.withDefaults(event)
+	eventsURL := c.events()
+	if eventsURL == nil {
 		return errors.New("eventsURL not initialised - you must post a pack def first")
 	}
-	resp, err := c.post(c.eventsURL, event)
+	sent := time.Now()
+	resp, err := c.postEvent(ctx, OperationPostEvent, eventsURL, event)
+	if err == nil && isStale(resp) {
+		if u, _ := c.rediscover(sent, eventsURL, (*links).events); u != nil {
+			resp.Body.Close()
+			eventsURL = u
+			resp, err = c.postEvent(ctx, OperationPostEvent, eventsURL, event)
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("error posting event %+v to %s: %v", event, c.eventsURL.String(), err)
+		return fmt.Errorf("error posting event %s to %s: %w", c.redact(event), eventsURL.String(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("event %+v not accepted, response was: %+v", event, resp)
+		return fmt.Errorf("event %s not accepted, response was: %s", c.redact(event), describeResponse(resp))
 	}
 	return nil
 }
 
 // TakeAction takes the next action the pack should process. If no action is available, nil is returned.
 func (c client) TakeAction() (*Action, error) {
-	if c.takeActionURL == nil {
+	return c.TakeActionContext(context.Background())
+}
+
+// TakeActionContext takes the next action in the same way as TakeAction, with the trace context of ctx. If the take
+// action url is not found, the links are discovered again and the action is taken again. A NotFoundError is only
+// returned if the take action url is still not found once the pack has been registered again; if the links cannot be
+// discovered again, the error returned can be retried.
+func (c client) TakeActionContext(ctx context.Context) (*Action, error) {
+	takeActionURL := c.takeAction()
+	if takeActionURL == nil {
 		return nil, errors.New("takeActionURL not initialised - you must post a pack def first")
 	}
 
-	resp, err := c.post(c.takeActionURL, nil)
+	sent := time.Now()
+	resp, err := c.post(ctx, OperationTakeAction, takeActionURL, nil)
+	if err == nil && isStale(resp) {
+		u, rerr := c.rediscover(sent, takeActionURL, (*links).takeAction)
+		if rerr != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error taking action from %s, it was not found and the links cannot be discovered again: %w", takeActionURL.String(), rerr)
+		}
+		if u != nil {
+			resp.Body.Close()
+			takeActionURL = u
+			resp, err = c.post(ctx, OperationTakeAction, takeActionURL, nil)
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error taking action from %s: %v", c.takeActionURL.String(), err)
+		return nil, fmt.Errorf("error taking action from %s: %w", takeActionURL.String(), err)
 	}
 	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		a := &Action{}
-		err = json.NewDecoder(resp.Body).Decode(a)
-		return a, err
+		a := &Action{source: takeActionURL}
+		if err = c.decode(resp, a); err != nil {
+			return nil, fmt.Errorf("could not deserialise action from %s: %w", takeActionURL.String(), err)
+		}
+		return a, nil
 	case http.StatusNoContent:
 		return nil, nil
 	case http.StatusNotFound:
-		return nil, NotFoundError{fmt.Sprintf("resource not found at %s", c.takeActionURL.String())}
+		return nil, NotFoundError{fmt.Sprintf("resource not found at %s", takeActionURL.String())}
 	default:
-		return nil, fmt.Errorf("error taking action from %s, response was: %+v", c.takeActionURL.String(), resp)
+		return nil, fmt.Errorf("error taking action from %s, response was: %s", takeActionURL.String(), describeResponse(resp))
 	}
 }
 
 // CompleteAction posts the action result to the flyte server.
 func (c client) CompleteAction(action Action, event Event) error {
-	event.CreatedAt = time.Now().UTC()
-	resultURL, err := findURLByRel(action.Links, "actionResult")
+	return c.CompleteActionContext(context.Background(), action, event)
+}
+
+// CompleteActionContext posts the action result to the flyte server, with the trace context of ctx. As with
+// PostEventContext, the event ID is sent as the Idempotency-Key header.
+func (c client) CompleteActionContext(ctx context.Context, action Action, event Event) error {
+	event = c.withDefaults(event)
+	base := action.source
+	if base == nil {
+		base = c.takeAction()
+	}
+	resultURL, err := c.findURLByRel(base, action.Links, "actionResult")
 	if err != nil {
 		return err
 	}
-	resp, err := c.post(resultURL, event)
+	resp, err := c.postEvent(ctx, OperationCompleteAction, resultURL, event)
 	if err != nil {
-		return fmt.Errorf("error posting action result %+v to %s: %v", event, resultURL.String(), err)
+		return fmt.Errorf("error posting action result %s to %s: %w", c.redact(event), resultURL.String(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("action result event %+v not processed successfully by flyte api, response was: %+v", event, resp)
+		return fmt.Errorf("action result event %s not processed successfully by flyte api, response was: %s", c.redact(event), describeResponse(resp))
 	}
 	return nil
 }
 
-// findURLByRel returns a link URL if found from the links passed in, else it will return an error.
-func findURLByRel(links []Link, rel string) (*url.URL, error) {
-	for _, l := range links {
-		if strings.HasSuffix(l.Rel, rel) {
-			return l.Href, nil
-		}
+// sets the time the event is sent, and fills the envelope fields the event does not set: a new ID, the time it
+// occurred as the time it is sent and the pack as the source
+func (c client) withDefaults(e Event) Event {
+	if e.ID == "" {
+		e.ID = NewEventID()
+	}
+	e.CreatedAt = time.Now().UTC()
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = e.CreatedAt
+	}
+	if e.Source == "" {
+		e.Source = c.source()
+	}
+	return e
+}
+
+// CircuitState returns the state of the circuit breaker, and false if it is disabled.
+func (c client) CircuitState() (CircuitState, bool) {
+	return c.breaker.currentState()
+}
+
+// describes a value such as an event for an error message, with secrets masked
+func (c client) redact(v interface{}) string {
+	return redact.OrDefault(c.redactor).Redact(v)
+}
+
+// describes an unexpected response for an error message by its status code and url, with any password masked. The
+// rest of the response, e.g. its headers, may contain secrets
+func describeResponse(resp *http.Response) string {
+	if resp.Request == nil {
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("status %d from %s", resp.StatusCode, redact.URL(resp.Request.URL))
+}
+
+// findURLByRel returns the URL of the link with the relation from the links passed in. A relative href is resolved
+// against base, the url the links were read from, and a templated href is expanded with the registered pack name.
+// Returns a *LinkError if no link, or more than one, has the relation.
+func (c client) findURLByRel(base *url.URL, links []Link, rel string) (*url.URL, error) {
+	return newLinkRegistry(base, links, templateVars(c.source())).find(rel)
+}
+
+// the variables of templated links: the pack name, as packName
+func templateVars(packName string) map[string]string {
+	if packName == "" {
+		return nil
 	}
-	return nil, fmt.Errorf("could not find link with rel %q in %v", rel, links)
+	return map[string]string{"packName": packName}
 }
 
 type NotFoundError struct {
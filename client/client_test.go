@@ -18,15 +18,22 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/ExpediaGroup/flyte-client/config"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
+	"github.com/ExpediaGroup/flyte-client/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -69,11 +76,60 @@ func Test_NewClient_ShouldNotSendAuthorizationHeaderWhenRetrievingApiLinks(t *te
 	assert.Equal(t, "", rec.reqs[0].Header.Get("Authorization"))
 }
 
+func Test_NewClient_ShouldSendAuthorizationHeaderFromJWTOption(t *testing.T) {
+	// given the jwt environment variable exists
+	defer restoreGetEnvFunc()
+	defer clearEnv()
+	initTestEnv()
+	setEnv(config.FlyteJWTEnvName, "env.jwt.token")
+
+	// and we have a running server set to respond with flyte api links
+	ts, rec := mockServerWithRecorder(http.StatusCreated, flyteApiLinksResponse)
+	defer ts.Close()
+
+	// when we create a new client with a jwt option
+	baseUrl, _ := url.Parse(ts.URL)
+	NewClient(baseUrl, 10*time.Second, WithJWT("option.jwt.token"))
+
+	// then the option takes precedence over the environment
+	require.NotEmpty(t, rec.reqs, "A http request must be set!")
+	assert.Equal(t, "Bearer option.jwt.token", rec.reqs[0].Header.Get("Authorization"))
+}
+
+func Test_NewClient_ShouldNotSendAuthorizationHeaderWhenTheJWTOptionIsEmpty(t *testing.T) {
+	// given the jwt environment variable exists
+	defer restoreGetEnvFunc()
+	defer clearEnv()
+	initTestEnv()
+	setEnv(config.FlyteJWTEnvName, "env.jwt.token")
+
+	// and we have a running server set to respond with flyte api links
+	ts, rec := mockServerWithRecorder(http.StatusCreated, flyteApiLinksResponse)
+	defer ts.Close()
+
+	// when we create a new client with an empty jwt option, e.g. from configuration without a jwt
+	baseUrl, _ := url.Parse(ts.URL)
+	NewClient(baseUrl, 10*time.Second, WithJWT(""))
+
+	// then the environment is not used
+	require.NotEmpty(t, rec.reqs, "A http request must be set!")
+	assert.Equal(t, "", rec.reqs[0].Header.Get("Authorization"))
+}
+
+func Test_NewHttpClient_ShouldUseTLSConfigOption(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "flyte.example.com"}
+
+	httpClient := newHttpClient(10*time.Second, true, WithTLSConfig(tlsConfig))
+
+	transport := httpClient.Transport.(*http.Transport)
+	assert.Equal(t, "flyte.example.com", transport.TLSClientConfig.ServerName)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+	// the config passed in is not modified
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+}
+
 func Test_NewClient_ShouldRetryOnErrorGettingFlyteApiLinks(t *testing.T) {
 	// given the mock flyte-api will first return an error response getting api links...then after retrying will return the expected response
-	prevFlyteApiRetryWait := flyteApiRetryWait
-	defer func() {flyteApiRetryWait = prevFlyteApiRetryWait}()
-	flyteApiRetryWait = 0
 	apiLinksFailCount := 1
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		if apiLinksFailCount > 0 {
@@ -96,6 +152,36 @@ func Test_NewClient_ShouldRetryOnErrorGettingFlyteApiLinks(t *testing.T) {
 	assert.Equal(t, "http://example.com/v1/health", healthCheckURL.String())
 }
 
+func Test_NewClient_ShouldStopGettingFlyteApiLinksAfterTheRetryPolicyAttempts(t *testing.T) {
+	// given flyte-api returns an error response getting api links until it recovers
+	var requests int32
+	var recovered int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&recovered) == 0 {
+			w.Write(bytes.NewBufferString(flyteApiErrorResponse).Bytes())
+			return
+		}
+		w.Write(bytes.NewBufferString(flyteApiLinksResponse).Bytes())
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	baseUrl, _ := url.Parse(server.URL)
+
+	// when
+	c := NewClient(baseUrl, 10*time.Second, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}, OperationAPILinks)).(*client)
+
+	// then the client is created without the links
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Nil(t, c.api())
+
+	// and the links are retrieved when they are needed to register the pack
+	atomic.StoreInt32(&recovered, 1)
+	packsURL, err := c.getPacksURL()
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/v1/packs", packsURL.String())
+}
+
 func Test_InsecureNewClient_ShouldNotLogFatalWhenJWTIsNotProvided(t *testing.T) {
 	// given no jwt exists in the environment var and server is set up
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -115,9 +201,6 @@ func Test_InsecureNewClient_ShouldNotLogFatalWhenJWTIsNotProvided(t *testing.T)
 }
 
 func Test_InsecureNewClient_ShouldRetryOnErrorGettingFlyteApiLinks(t *testing.T) {
-	prevFlyteApiRetryWait := flyteApiRetryWait
-	defer func() {flyteApiRetryWait = prevFlyteApiRetryWait}()
-	flyteApiRetryWait = 0
 	// given the mock flyte-api will first return an error response getting api links...then after retrying will return the expected response
 	apiLinksFailCount := 1
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -230,7 +313,7 @@ func Test_CreatePack_ShouldReturnErrorWhenLinksDoNotContainCorrectRel(t *testing
 
 	c := &client{
 		httpClient: &http.Client{Timeout: 5 * time.Second},
-		apiLinks:   emptyLinks,
+		links:      &links{apiLinks: emptyLinks},
 	}
 
 	err := c.CreatePack(Pack{Name: "Slack"})
@@ -249,11 +332,10 @@ func Test_CreatePack_ShouldReturnErrorIfPostingPackFails(t *testing.T) {
 	// and a client setup to call the above server
 	baseUrl, _ := url.Parse(ts.URL)
 	c := &client{
-		baseURL: baseUrl,
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
-		apiLinks: map[string][]Link{"links": {{Href: baseUrl, Rel: "pack/listPacks"}}},
+		links: &links{baseURL: baseUrl, apiLinks: map[string][]Link{"links": {{Href: baseUrl, Rel: "pack/listPacks"}}}},
 	}
 
 	// when
@@ -320,12 +402,119 @@ func Test_PostEvent_ShouldPostEvent(t *testing.T) {
 	require.NoError(t, json.Unmarshal(rec.body[0], &got))
 
 	want.CreatedAt = got.CreatedAt
+	// the event occurred when it is sent, unless it sets when it occurred
+	assert.Equal(t, got.CreatedAt, got.OccurredAt)
+	want.OccurredAt = got.OccurredAt
+	// the client generates the event id, and sends it as the idempotency key
+	require.NotEmpty(t, got.ID)
+	assert.Equal(t, got.ID, rec.reqs[0].Header.Get(IdempotencyKeyHeader))
+	want.ID = got.ID
 
 	assert.Equal(t, want, got)
 	assert.True(t, time.Now().UTC().Sub(want.CreatedAt) >= 0)
 	assert.True(t, beforePost.Sub(want.CreatedAt) <= 0)
 }
 
+func Test_PostEvent_ShouldKeepTheEnvelopeFieldsTheCallerSets(t *testing.T) {
+	// given
+	ts, rec := mockServerWithRecorder(http.StatusAccepted, "")
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+	c.setPack(c.eventsURL, nil, Pack{Name: "Slack"}, EncodingFlyte)
+
+	// when a replayed event is posted
+	occurredAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	err := c.PostEvent(Event{
+		Name:          "MessageSent",
+		OccurredAt:    occurredAt,
+		CorrelationID: "deploy-42",
+		Source:        "slack-replay",
+		Metadata:      map[string]string{"schemaVersion": "2"},
+	})
+
+	// then
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.body[0], &got))
+	assert.Equal(t, "2020-03-04T05:06:07Z", got["occurredAt"])
+	assert.NotEqual(t, got["occurredAt"], got["createdAt"], "createdAt is the time the event is sent")
+	assert.Equal(t, "deploy-42", got["correlationId"])
+	assert.Equal(t, "slack-replay", got["source"])
+	assert.Equal(t, map[string]interface{}{"schemaVersion": "2"}, got["metadata"])
+}
+
+func Test_PostEvent_ShouldSetThePackAsTheSource(t *testing.T) {
+	// given a client registered as the Slack pack
+	ts, rec := mockServerWithRecorder(http.StatusCreated, slackPackResponse)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	require.NoError(t, c.CreatePack(Pack{Name: "Slack"}))
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when
+	c.PostEvent(Event{Name: "MessageSent"})
+
+	// then the optional fields that are not set are left out
+	require.Len(t, rec.body, 2)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.body[1], &got))
+	assert.Equal(t, "Slack", got["source"])
+	assert.NotContains(t, got, "correlationId")
+	assert.NotContains(t, got, "metadata")
+}
+
+func Test_PostEvent_ShouldRedactPayloadInError(t *testing.T) {
+	// given a server that does not accept events
+	ts := mockServer(http.StatusBadRequest, "")
+	defer ts.Close()
+
+	// and a client with a redaction policy
+	c := newTestClient(ts.URL, t)
+	c.eventsURL, _ = url.Parse(ts.URL + "/v1/packs/Slack/events")
+	c.redactor = redact.Policy{Fields: []string{"$.payload.user.pin"}}
+
+	// when
+	err := c.PostEvent(Event{Name: "LoggedIn", Payload: map[string]interface{}{"user": map[string]string{"name": "ann", "pin": "1234"}}})
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"payload":{"user":{"name":"ann","pin":"[REDACTED]"}}`)
+	assert.NotContains(t, err.Error(), "1234")
+}
+
+func Test_CompleteAction_ShouldRedactDefaultFieldsInError(t *testing.T) {
+	ts := mockServer(http.StatusBadRequest, "")
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	resultURL, _ := url.Parse(ts.URL + "/v1/packs/Slack/actions/1/result")
+	action := Action{Links: []Link{{Href: resultURL, Rel: "http://example.com/swagger#!/action/actionResult"}}}
+
+	err := c.CompleteAction(action, Event{Name: "Deployed", Payload: map[string]string{"token": "s3cr3t"}})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"payload":{"token":"[REDACTED]"}`)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
+
+func Test_CompleteAction_ShouldOnlyDescribeTheResponseStatusAndRedactedURLInError(t *testing.T) {
+	ts := mockServer(http.StatusBadRequest, "")
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	resultURL, _ := url.Parse(ts.URL + "/v1/packs/Slack/actions/1/result")
+	resultURL.User = url.UserPassword("user", "pa55")
+	action := Action{Links: []Link{{Href: resultURL, Rel: "http://example.com/swagger#!/action/actionResult"}}}
+
+	err := c.CompleteAction(action, Event{Name: "Deployed"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "response was: status 400 from http://user:%2A%2A%2A%2A@")
+	assert.NotContains(t, err.Error(), "pa55")
+	assert.NotContains(t, err.Error(), "Header")
+}
+
 func Test_PostEvent_ShouldSendAuthorizationHeader(t *testing.T) {
 	// given we have a running server
 	ts, rec := mockServerWithRecorder(http.StatusAccepted, `{"some":"response"}`)
@@ -441,6 +630,64 @@ func Test_TakeAction_ShouldReturnSpecificErrorTypeAndMessageWhenResourceIsNotFou
 	assert.EqualError(t, err, fmt.Sprintf("resource not found at %s/take/action/url", ts.URL))
 }
 
+func Test_TakeActionContext_ShouldSendTraceParentOfTheSpanInTheContext(t *testing.T) {
+	// given we have a running server
+	ts, rec := mockServerWithRecorder(http.StatusNoContent, "")
+	defer ts.Close()
+
+	// and a client
+	c := newTestClient(ts.URL, t)
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take/action/url")
+
+	// and a context with a span
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), tracing.SpanTakeAction)
+	defer span.End()
+
+	// when
+	_, err := c.TakeActionContext(ctx)
+
+	// then
+	require.NoError(t, err)
+	require.NotEmpty(t, rec.reqs, "A http request must be set!")
+	sc := span.SpanContext()
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", rec.reqs[0].Header.Get("traceparent"))
+}
+
+func Test_TakeAction_ShouldNotSendTraceParent(t *testing.T) {
+	ts, rec := mockServerWithRecorder(http.StatusNoContent, "")
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take/action/url")
+
+	_, err := c.TakeAction()
+
+	require.NoError(t, err)
+	require.NotEmpty(t, rec.reqs, "A http request must be set!")
+	assert.Equal(t, "", rec.reqs[0].Header.Get("traceparent"))
+}
+
+func Test_TakeAction_ShouldRecordRequestMetrics(t *testing.T) {
+	// given a client recording metrics
+	ts := mockServer(http.StatusNoContent, "")
+	defer ts.Close()
+
+	m := metrics.NewPrometheus()
+	c := newTestClient(ts.URL, t)
+	c.metrics = m
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take/action/url")
+
+	// when
+	_, err := c.TakeAction()
+
+	// then
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `flyte_client_requests_total{endpoint="takeAction",status="204"} 1`)
+	assert.Contains(t, rec.Body.String(), `flyte_client_request_duration_seconds_count{endpoint="takeAction",status="204"} 1`)
+}
+
 /**
   CompleteAction tests
 */
@@ -644,7 +891,7 @@ func newTestClient(serverURL string, t *testing.T) *client {
 
 	return &client{
 		httpClient: newHttpClient(5*time.Second, false),
-		apiLinks:   map[string][]Link{"links": {{Href: u, Rel: "pack/listPacks"}}},
+		links:      &links{apiLinks: map[string][]Link{"links": {{Href: u, Rel: "pack/listPacks"}}}},
 	}
 }
 
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EventEncoding is how events and action results are sent to flyte-api.
+type EventEncoding string
+
+const (
+	// EncodingFlyte sends events in the flyte format, {"event", "payload", "createdAt", "occurredAt", ...}. It is the default.
+	EncodingFlyte EventEncoding = "flyte"
+	// EncodingCloudEventsStructured sends events as CloudEvents 1.0 in structured content mode: the whole event is
+	// the JSON body, with the application/cloudevents+json content type.
+	EncodingCloudEventsStructured EventEncoding = "cloudevents-structured"
+	// EncodingCloudEventsBinary sends events as CloudEvents 1.0 in binary content mode: the payload is the JSON body,
+	// and the other attributes are ce- headers.
+	EncodingCloudEventsBinary EventEncoding = "cloudevents-binary"
+	// EncodingNegotiate uses the CloudEvents mode flyte-api advertises with a link whose rel ends with
+	// "cloudevents/structured" or "cloudevents/binary", on the registered pack or the api links. Without one, events
+	// are sent in the flyte format. The mode is negotiated again each time the pack is registered again.
+	EncodingNegotiate EventEncoding = "negotiate"
+)
+
+const (
+	cloudEventsSpecVersion  = "1.0"
+	cloudEventsContentType  = "application/cloudevents+json"
+	cloudEventsHeaderPrefix = "Ce-"
+)
+
+// the attributes defined by the CloudEvents specification, which metadata cannot replace
+var cloudEventsAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "datacontenttype": true, "dataschema": true,
+	"subject": true, "time": true, "data": true, "data_base64": true,
+}
+
+// the rels flyte-api advertises the CloudEvents modes with, in order of preference
+var cloudEventsRels = []struct {
+	rel      string
+	encoding EventEncoding
+}{
+	{"cloudevents/structured", EncodingCloudEventsStructured},
+	{"cloudevents/binary", EncodingCloudEventsBinary},
+}
+
+// the encoding advertised by the links, or EncodingFlyte if none is
+func negotiateEncoding(links ...[]Link) EventEncoding {
+	for _, l := range links {
+		for _, ce := range cloudEventsRels {
+			if _, err := newLinkRegistry(nil, l, nil).find(ce.rel); err == nil {
+				return ce.encoding
+			}
+		}
+	}
+	return EncodingFlyte
+}
+
+// the encoding events are sent in: the configured one, or the one negotiated when the pack was last registered.
+// Events are sent in the flyte format until a negotiated encoding is known
+func (c client) eventEncoding() EventEncoding {
+	if c.encoding == EncodingNegotiate {
+		return c.negotiatedEncoding()
+	}
+	return c.encoding
+}
+
+// posts the event in the encoding in use, with its ID as the Idempotency-Key header, so flyte-api can recognise an
+// event it has already processed when the request is retried
+func (c client) postEvent(ctx context.Context, op Operation, u *url.URL, e Event) (*http.Response, error) {
+	header := http.Header{}
+	header.Set(IdempotencyKeyHeader, e.ID)
+
+	switch c.eventEncoding() {
+	case EncodingCloudEventsStructured:
+		header.Set("Content-Type", cloudEventsContentType)
+		return c.postWithHeader(ctx, op, u, structuredCloudEvent(e), header)
+	case EncodingCloudEventsBinary:
+		for name, value := range cloudEventAttributes(e) {
+			header.Set(cloudEventsHeaderPrefix+name, encodeHeaderValue(value))
+		}
+		return c.postWithHeader(ctx, op, u, e.Payload, header)
+	}
+	return c.postWithHeader(ctx, op, u, e, header)
+}
+
+// the CloudEvents context attributes of the event: the ID, the pack as the source, the event name as the type, the
+// time, and the correlation ID and metadata as extensions
+func cloudEventAttributes(e Event) map[string]string {
+	attributes := make(map[string]string)
+	for k, v := range e.Metadata {
+		if name := extensionName(k); name != "" && !cloudEventsAttributes[name] {
+			attributes[name] = v
+		}
+	}
+	if e.CorrelationID != "" {
+		attributes["correlationid"] = e.CorrelationID
+	}
+	attributes["specversion"] = cloudEventsSpecVersion
+	attributes["id"] = e.ID
+	attributes["source"] = e.Source
+	attributes["type"] = e.Name
+	attributes["time"] = e.OccurredAt.Format(time.RFC3339Nano)
+	attributes["datacontenttype"] = "application/json"
+	return attributes
+}
+
+// the event in structured content mode, the attributes with the payload as data
+func structuredCloudEvent(e Event) map[string]interface{} {
+	ce := make(map[string]interface{})
+	for name, value := range cloudEventAttributes(e) {
+		ce[name] = value
+	}
+	if e.Payload != nil {
+		ce["data"] = e.Payload
+	}
+	return ce
+}
+
+// percent-encodes the characters the CloudEvents http binding does not allow in header values: those outside printable
+// ASCII, and '"' and '%'
+func encodeHeaderValue(v string) string {
+	var b strings.Builder
+	for _, c := range []byte(v) {
+		if c < ' ' || c > '~' || c == '"' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// CloudEvents extension names are lower case letters and digits, so metadata keys are lower cased and other
+// characters removed, e.g. "schema-Version" is sent as "schemaversion"
+func extensionName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(key) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
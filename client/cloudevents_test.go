@@ -0,0 +1,163 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var deployedEvent = Event{
+	ID:            "3f1c5d9e-0b7a-4c2e-9d41-5a6b7c8d9e0f",
+	Name:          "Deployed",
+	Payload:       map[string]string{"version": "1.2"},
+	OccurredAt:    time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+	CorrelationID: "deploy-42",
+	Source:        "Jenkins",
+	Metadata:      map[string]string{"schema-Version": "2", "type": "not an extension"},
+}
+
+func Test_PostEvent_ShouldSendStructuredCloudEvents(t *testing.T) {
+	// given
+	ts, rec := mockServerWithRecorder(http.StatusAccepted, "")
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.encoding = EncodingCloudEventsStructured
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when
+	err := c.PostEvent(deployedEvent)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "application/cloudevents+json", rec.reqs[0].Header.Get("Content-Type"))
+	assert.Equal(t, deployedEvent.ID, rec.reqs[0].Header.Get(IdempotencyKeyHeader))
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "3f1c5d9e-0b7a-4c2e-9d41-5a6b7c8d9e0f",
+		"source": "Jenkins",
+		"type": "Deployed",
+		"time": "2020-03-04T05:06:07Z",
+		"datacontenttype": "application/json",
+		"correlationid": "deploy-42",
+		"schemaversion": "2",
+		"data": {"version": "1.2"}
+	}`, string(rec.body[0]))
+}
+
+func Test_CompleteAction_ShouldSendBinaryCloudEvents(t *testing.T) {
+	// given
+	ts, rec := mockServerWithRecorder(http.StatusAccepted, "")
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.encoding = EncodingCloudEventsBinary
+	resultURL, _ := url.Parse(ts.URL + "/actionResult")
+	e := deployedEvent
+	e.Source = "Jenkins café"
+
+	// when
+	err := c.CompleteAction(Action{Links: []Link{{Href: resultURL, Rel: "actionResult"}}}, e)
+
+	// then the payload is the body, and the attributes are headers
+	require.NoError(t, err)
+	h := rec.reqs[0].Header
+	assert.Equal(t, "application/json", h.Get("Content-Type"))
+	assert.Equal(t, "1.0", h.Get("ce-specversion"))
+	assert.Equal(t, e.ID, h.Get("ce-id"))
+	assert.Equal(t, "Jenkins caf%C3%A9", h.Get("ce-source"))
+	assert.Equal(t, "Deployed", h.Get("ce-type"))
+	assert.Equal(t, "2020-03-04T05:06:07Z", h.Get("ce-time"))
+	assert.Equal(t, "deploy-42", h.Get("ce-correlationid"))
+	assert.Equal(t, "2", h.Get("ce-schemaversion"))
+	assert.JSONEq(t, `{"version": "1.2"}`, string(rec.body[0]))
+}
+
+func Test_CreatePack_ShouldNegotiateTheEventEncoding(t *testing.T) {
+	for response, want := range map[string]EventEncoding{
+		slackPackResponse: EncodingFlyte,
+		strings.Replace(slackPackResponse, `"links": [`, `"links": [{"href": "http://example.com/v1/packs/Slack/events", "rel": "http://example.com/swagger#/cloudevents/binary"},`, 1): EncodingCloudEventsBinary,
+	} {
+		ts := mockServer(http.StatusCreated, response)
+		c := newTestClient(ts.URL, t)
+		c.encoding = EncodingNegotiate
+
+		require.NoError(t, c.CreatePack(Pack{Name: "Slack"}))
+
+		assert.Equal(t, want, c.eventEncoding())
+		ts.Close()
+	}
+}
+
+func Test_CreatePack_ShouldKeepTheConfiguredEventEncoding(t *testing.T) {
+	ts := mockServer(http.StatusCreated, strings.Replace(slackPackResponse, "#/event", "#/cloudevents/binary", 1))
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.encoding = EncodingCloudEventsStructured
+
+	c.CreatePack(Pack{Name: "Slack"})
+
+	assert.Equal(t, EncodingCloudEventsStructured, c.eventEncoding())
+}
+
+func Test_RefreshLinks_ShouldNegotiateTheEventEncodingAgain(t *testing.T) {
+	// given a flyte-api that starts advertising binary CloudEvents after it is redeployed
+	var mu sync.Mutex
+	packLinks := `[{"href": "/v1/packs/Slack/events", "rel": "event"}, {"href": "/v1/packs/Slack/actions/take", "rel": "takeAction"}]`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/v1" {
+			w.Write([]byte(`{"links": [{"href": "/v1/packs", "rel": "pack/listPacks"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"name": "Slack", "links": ` + packLinks + `}`))
+	}))
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL, WithEventEncoding(EncodingNegotiate))
+	require.Equal(t, EncodingFlyte, c.eventEncoding())
+
+	// when
+	mu.Lock()
+	packLinks = `[{"href": "/v1/packs/Slack/events", "rel": "event"}, {"href": "/v1/packs/Slack/actions/take", "rel": "takeAction"}, {"href": "/v1/packs/Slack/events", "rel": "cloudevents/binary"}]`
+	mu.Unlock()
+	require.NoError(t, c.refreshLinks(time.Now()))
+
+	// then
+	assert.Equal(t, EncodingCloudEventsBinary, c.eventEncoding())
+}
+
+func TestExtensionName_ShouldOnlyKeepLowerCaseLettersAndDigits(t *testing.T) {
+	assert.Equal(t, "schemaversion2", extensionName("Schema_Version-2"))
+	assert.Equal(t, "", extensionName("--"))
+}
+
+func TestStructuredCloudEvent_ShouldLeaveOutMissingData(t *testing.T) {
+	b, err := json.Marshal(structuredCloudEvent(Event{ID: "1", Name: "Started", Source: "Jenkins"}))
+
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), `"data"`)
+	assert.NotContains(t, string(b), `"correlationid"`)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrResponseTooLarge is returned when a flyte api response body is larger than the limit set by WithMaxResponseSize.
+var ErrResponseTooLarge = errors.New("flyte api response is larger than the maximum response size")
+
+// Compression gzips request bodies of at least Threshold bytes. Bodies are only compressed once flyte-api has
+// advertised that it accepts gzip requests, with an Accept-Encoding response header.
+type Compression struct {
+	Threshold int // in bytes, 0 disables compression
+	Level     int // the gzip level, defaults to gzip.DefaultCompression
+}
+
+// compresses request bodies, sharing whether flyte-api accepts gzip between copies of the client. A nil compressor
+// never compresses
+type compressor struct {
+	Compression
+	accepted int32 // 1 once flyte-api has advertised that it accepts gzip
+}
+
+func newCompressor(c Compression) *compressor {
+	if c.Threshold <= 0 {
+		return nil
+	}
+	if c.Level == 0 {
+		c.Level = gzip.DefaultCompression
+	}
+	return &compressor{Compression: c}
+}
+
+// records whether flyte-api accepts gzip requests from the Accept-Encoding header of its response. A 415 response
+// without the header means it does not
+func (c *compressor) observe(resp *http.Response) {
+	if c == nil {
+		return
+	}
+	if _, set := resp.Header["Accept-Encoding"]; !set && resp.StatusCode != http.StatusUnsupportedMediaType {
+		return
+	}
+	var accepted int32
+	if acceptsGzip(resp.Header) {
+		accepted = 1
+	}
+	atomic.StoreInt32(&c.accepted, accepted)
+}
+
+// returns the gzipped body and true if it should be compressed, else the body unchanged
+func (c *compressor) compress(b []byte) ([]byte, bool) {
+	if c == nil || len(b) < c.Threshold || atomic.LoadInt32(&c.accepted) == 0 {
+		return b, false
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.Level)
+	if err != nil {
+		return b, false
+	}
+	if _, err := w.Write(b); err != nil {
+		return b, false
+	}
+	if err := w.Close(); err != nil {
+		return b, false
+	}
+	return buf.Bytes(), true
+}
+
+// whether the Accept-Encoding header accepts gzip: it is listed, or "*" is and gzip is not, with a q-value above 0
+func acceptsGzip(h http.Header) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, v := range h.Values("Accept-Encoding") {
+		for _, item := range strings.Split(v, ",") {
+			params := strings.Split(item, ";")
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case "gzip", "x-gzip":
+				gzipQ = qValue(params[1:])
+			case "*":
+				anyQ = qValue(params[1:])
+			}
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// the q-value of a coding from its parameters, 1 if it is not set, and 0 if it is not valid
+func qValue(params []string) float64 {
+	for _, p := range params {
+		name, value, found := strings.Cut(strings.TrimSpace(p), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// replaces the body of a gzip encoded response with the decompressed body, so callers read it as if it was sent
+// uncompressed
+func decompress(resp *http.Response) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return
+	}
+	resp.Body = &gzipBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompresses the response body as it is read. The gzip header is read on the first read, so a response that is
+// never read is not waited for
+type gzipBody struct {
+	body io.ReadCloser
+	r    *gzip.Reader
+	err  error
+}
+
+func (g *gzipBody) Read(p []byte) (int, error) {
+	if g.r == nil && g.err == nil {
+		g.r, g.err = gzip.NewReader(g.body)
+	}
+	if g.err != nil {
+		return 0, g.err
+	}
+	return g.r.Read(p)
+}
+
+func (g *gzipBody) Close() error {
+	return g.body.Close()
+}
+
+// limits the bytes read from a response body, failing with ErrResponseTooLarge once more than max bytes are read.
+// A max of 0 means no limit
+func limitBody(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &limitedBody{r: r, remaining: max}
+}
+
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = -1
+		return n, ErrResponseTooLarge
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
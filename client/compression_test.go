@@ -0,0 +1,190 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// records the decompressed request bodies, advertising gzip support and responding with the statuses passed in,
+// one per request
+func gzipServer(t *testing.T, statuses ...int) (*httptest.Server, *[]string, *[]string) {
+	var encodings, bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.Body
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		bodies = append(bodies, string(b))
+		w.Header().Set("Accept-Encoding", "gzip")
+		w.WriteHeader(statuses[len(bodies)-1])
+	}))
+	return ts, &encodings, &bodies
+}
+
+func Test_PostEvent_ShouldCompressLargeBodiesOnceFlyteApiAcceptsGzip(t *testing.T) {
+	// given
+	ts, encodings, bodies := gzipServer(t, http.StatusAccepted, http.StatusAccepted, http.StatusAccepted)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.compressor = newCompressor(Compression{Threshold: 1024})
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+	large := Event{Name: "BuildLog", Payload: strings.Repeat("log line\n", 200)}
+
+	// when
+	require.NoError(t, c.PostEvent(large))
+	require.NoError(t, c.PostEvent(large))
+	require.NoError(t, c.PostEvent(Event{Name: "BuildLog", Payload: "short"}))
+
+	// then the first request is not compressed, as flyte-api has not advertised gzip yet, nor are small bodies
+	assert.Equal(t, []string{"", "gzip", ""}, *encodings)
+	assert.Contains(t, (*bodies)[1], strings.Repeat("log line\\n", 200))
+}
+
+func Test_PostEvent_ShouldResendUncompressedWhenFlyteApiRejectsGzip(t *testing.T) {
+	// given
+	ts, encodings, bodies := gzipServer(t, http.StatusUnsupportedMediaType, http.StatusAccepted)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.compressor = newCompressor(Compression{Threshold: 1})
+	c.compressor.accepted = 1
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when
+	err := c.PostEvent(Event{Name: "BuildLog", Payload: "log"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gzip", ""}, *encodings)
+	assert.Equal(t, (*bodies)[0], (*bodies)[1])
+}
+
+func Test_TakeAction_ShouldDecompressGzipResponses(t *testing.T) {
+	// given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"command": "sendMessage", "input": "hello"}`))
+		zw.Close()
+	}))
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.takeActionURL, _ = url.Parse(ts.URL + "/takeAction")
+
+	// when
+	action, err := c.TakeAction()
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "sendMessage", action.CommandName)
+	assert.JSONEq(t, `"hello"`, string(action.Input))
+}
+
+func Test_TakeAction_ShouldFailWhenTheResponseIsTooLarge(t *testing.T) {
+	// given
+	ts := mockServer(http.StatusOK, `{"command": "sendMessage", "input": "`+strings.Repeat("a", 1024)+`"}`)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.maxBodySize = 512
+	c.takeActionURL, _ = url.Parse(ts.URL + "/takeAction")
+
+	// when
+	action, err := c.TakeAction()
+
+	// then
+	assert.Nil(t, action)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), "%v", err)
+}
+
+func Test_GetStruct_ShouldFailWhenTheResponseIsTooLarge(t *testing.T) {
+	ts := mockServer(http.StatusOK, `{"links": [`+strings.Repeat(`{"href": "http://example.com", "rel": "self"},`, 100)+`{}]}`)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.maxBodySize = 1024
+	u, _ := url.Parse(ts.URL)
+
+	var links map[string][]Link
+	err := c.getStruct(context.Background(), OperationAPILinks, u, &links)
+
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), "%v", err)
+}
+
+func TestLimitBody_ShouldReadBodiesUpToTheLimit(t *testing.T) {
+	b, err := ioutil.ReadAll(limitBody(bytes.NewReader([]byte("12345")), 5))
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(b))
+
+	b, err = ioutil.ReadAll(limitBody(bytes.NewReader([]byte("123456")), 5))
+	assert.Equal(t, ErrResponseTooLarge, err)
+	assert.Equal(t, "12345", string(b))
+}
+
+func TestCompressor_ShouldFollowTheAcceptEncodingOfFlyteApi(t *testing.T) {
+	c := newCompressor(Compression{Threshold: 1})
+
+	c.observe(&http.Response{StatusCode: http.StatusOK, Header: http.Header{"Accept-Encoding": {"br, GZIP;q=0.5"}}})
+	_, accepted := c.compress([]byte("{}"))
+	assert.True(t, accepted)
+
+	c.observe(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+	_, accepted = c.compress([]byte("{}"))
+	assert.True(t, accepted, "responses without the header should not change it")
+
+	c.observe(&http.Response{StatusCode: http.StatusUnsupportedMediaType, Header: http.Header{}})
+	_, accepted = c.compress([]byte("{}"))
+	assert.False(t, accepted)
+
+	assert.Nil(t, newCompressor(Compression{}))
+}
+
+func TestAcceptsGzip_ShouldHonourQValues(t *testing.T) {
+	for encoding, accepted := range map[string]bool{
+		"gzip":              true,
+		"GZIP":              true,
+		"deflate, gzip":     true,
+		"gzip;q=0.5":        true,
+		"gzip; q=1.0":       true,
+		"gzip;q=0":          false,
+		"gzip;q=0.000":      false,
+		"gzip;q=invalid":    false,
+		"*":                 true,
+		"*;q=0":             false,
+		"*, gzip;q=0":       false,
+		"gzip;q=0.8, *;q=0": true,
+		"deflate":           false,
+		"gzipped":           false,
+		"":                  false,
+	} {
+		assert.Equal(t, accepted, acceptsGzip(http.Header{"Accept-Encoding": {encoding}}), "Accept-Encoding: %s", encoding)
+	}
+}
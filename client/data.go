@@ -28,7 +28,7 @@ type Pack struct {
 	Labels    map[string]string `json:"labels,omitempty"`   // pack labels - these act as a filter that determines when the pack will execute against a flow
 	EventDefs []EventDef        `json:"events"`             // the event definitions of a pack. These can be events a pack observes and sends spontaneously
 	Commands  []Command         `json:"commands,omitempty"` // the commands a pack exposes
-	Links     []Link            `json:"links, omitempty"`   // contains links the pack uses, such as the take action url and the events url, or links the pack exposes such as the pack help url
+	Links     []Link            `json:"links,omitempty"`    // contains links the pack uses, such as the take action url and the events url, or links the pack exposes such as the pack help url
 }
 
 // the event definition, this describes events a pack can send
@@ -45,46 +45,89 @@ type Command struct {
 }
 
 type Link struct {
-	Href *url.URL
-	Rel  string
+	Href      *url.URL
+	Rel       string
+	Templated bool // the href is a URI template (RFC 6570), expanded when the link is used
+
+	template string // the href as read, for templated links
 }
 
 // custom marshaller to avoid marshalling all url.URL fields
 func (l Link) MarshalJSON() ([]byte, error) {
+	href := l.Href.String()
+	if l.Templated {
+		href = l.hrefTemplate()
+	}
 	return json.Marshal(struct {
-		Href string `json:"href"`
-		Rel  string `json:"rel"`
+		Href      string `json:"href"`
+		Rel       string `json:"rel"`
+		Templated bool   `json:"templated,omitempty"`
 	}{
-		Href: l.Href.String(),
-		Rel:  l.Rel,
+		Href:      href,
+		Rel:       l.Rel,
+		Templated: l.Templated,
 	})
 }
 
 func (l *Link) UnmarshalJSON(data []byte) error {
 	linkRaw := &struct {
-		Href string `json:"href"`
-		Rel  string `json:"rel"`
+		Href      string `json:"href"`
+		Rel       string `json:"rel"`
+		Templated bool   `json:"templated"`
 	}{}
 	if err := json.Unmarshal(data, linkRaw); err != nil {
 		return err
 	}
 	href, err := url.Parse(linkRaw.Href)
-	if err != nil {
+	if err != nil && !linkRaw.Templated {
 		return err
 	}
 	l.Href = href
 	l.Rel = linkRaw.Rel
+	l.Templated = linkRaw.Templated
+	if l.Templated {
+		l.template = linkRaw.Href
+	}
 	return nil
 }
 
+// the URI template of a templated link. Links created in code, rather than read, keep the template in Href
+func (l Link) hrefTemplate() string {
+	if l.template != "" || l.Href == nil {
+		return l.template
+	}
+	t, err := url.PathUnescape(l.Href.String())
+	if err != nil {
+		return l.Href.String()
+	}
+	return t
+}
+
 type Event struct {
-	Name    string      `json:"event"`
-	Payload interface{} `json:"payload"`
-	CreatedAt time.Time `json:"createdAt"`
+	// ID identifies the event, and is sent as the Idempotency-Key header so flyte-api only processes it once. The
+	// client generates one if it is not set. Set it yourself to keep it the same when the event is sent again, e.g.
+	// to the ID of the message the event was observed from, or to NewEventID() before storing the event in an outbox
+	ID            string            `json:"id,omitempty"`
+	Name          string            `json:"event"`
+	Payload       interface{}       `json:"payload"`
+	CreatedAt     time.Time         `json:"createdAt"`               // when the event is sent. The client always sets it
+	OccurredAt    time.Time         `json:"occurredAt"`              // when the event happened, e.g. for replayed events. The client sets the time it is sent if not set
+	CorrelationID string            `json:"correlationId,omitempty"` // optional, relates the event to others, e.g. the events of one deployment
+	Source        string            `json:"source,omitempty"`        // what observed the event. The client sets the registered pack name if not set
+	Metadata      map[string]string `json:"metadata,omitempty"`      // optional, free-form details of the event, e.g. its schema version
 }
 
 type Action struct {
-	CommandName string          `json:"command"`
-	Input       json.RawMessage `json:"input"`
-	Links       []Link          `json:"links"`
+	CommandName string            `json:"command"`
+	Input       json.RawMessage   `json:"input"`
+	Links       []Link            `json:"links"`
+	Metadata    map[string]string `json:"metadata,omitempty"` // optional, e.g. the traceparent of the flow that created the action
+	source      *url.URL          // the url the action was taken from, which relative links are resolved against
+}
+
+// ResultURL returns the url the action result is posted to, from the "actionResult" link of the action. A relative
+// href is resolved against the url the action was taken from. Returns a *LinkError if no link, or more than one, has
+// the relation.
+func (a Action) ResultURL() (*url.URL, error) {
+	return newLinkRegistry(a.source, a.Links, nil).find("actionResult")
 }
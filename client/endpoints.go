@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// The defaults for the failover settings that are not set.
+const (
+	defaultFailoverThreshold = 3
+	defaultFailbackInterval  = time.Minute
+)
+
+// Failover sends requests to secondary flyte-api endpoints while the primary one is failing. After FailureThreshold
+// consecutive failed requests to the endpoint in use - connection errors, timeouts, 5xx responses or requests failed
+// fast by the circuit breaker - the client switches to the first other endpoint whose api links can be read, and
+// registers the pack with it again. While failed over the primary endpoint is checked every FailbackInterval, and the
+// client switches back once it is healthy.
+type Failover struct {
+	Endpoints        []*url.URL    // the root urls of the secondary endpoints, in order of preference
+	FailureThreshold int           // the consecutive failures that switch endpoint. Defaults to 3
+	FailbackInterval time.Duration // how often the primary endpoint is checked while failed over. Defaults to 1 minute
+}
+
+// EndpointReporter is implemented by clients that can fail over between flyte-api endpoints, so packs can report the
+// endpoint in use.
+type EndpointReporter interface {
+	// ActiveEndpoint returns the root url of the endpoint requests are sent to, and whether it is the primary endpoint.
+	ActiveEndpoint() (*url.URL, bool)
+}
+
+// which of the flyte-api endpoints is in use. Shared between copies of the client. A nil failover, or one without
+// secondary endpoints, never switches
+type failover struct {
+	settings Failover
+	roots    []*url.URL // the primary endpoint first
+	now      func() time.Time
+
+	mu        sync.Mutex
+	active    int
+	failures  int       // consecutive failures of the active endpoint
+	switching bool      // a switch of endpoint is in progress
+	checked   time.Time // when the primary endpoint was last checked while failed over
+}
+
+func newFailover(primary *url.URL, settings Failover) *failover {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = defaultFailoverThreshold
+	}
+	if settings.FailbackInterval <= 0 {
+		settings.FailbackInterval = defaultFailbackInterval
+	}
+	roots := append([]*url.URL{primary}, settings.Endpoints...)
+	return &failover{settings: settings, roots: roots, now: time.Now}
+}
+
+// records whether a request to the active endpoint failed. Returns true when the caller must switch endpoint, and
+// then call switched or stay
+func (f *failover) record(failed bool) bool {
+	if f == nil || len(f.roots) < 2 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.switching {
+		return false
+	}
+	if !failed {
+		f.failures = 0
+		return false
+	}
+	f.failures++
+	if f.failures < f.settings.FailureThreshold {
+		return false
+	}
+	f.switching = true
+	return true
+}
+
+// returns true when the caller must check whether the primary endpoint is healthy again, and then call switched or
+// stay
+func (f *failover) failbackDue() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active == 0 || f.switching || f.now().Sub(f.checked) < f.settings.FailbackInterval {
+		return false
+	}
+	f.switching, f.checked = true, f.now()
+	return true
+}
+
+// the endpoints to fail over to, in order of preference: the endpoints after the active one, then those before it
+func (f *failover) candidates() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var c []int
+	for i := 1; i < len(f.roots); i++ {
+		c = append(c, (f.active+i)%len(f.roots))
+	}
+	return c
+}
+
+func (f *failover) switched(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active, f.failures, f.switching = i, 0, false
+	f.checked = f.now()
+}
+
+// ends a switch that found no endpoint to switch to, so the active endpoint is used until it fails again
+func (f *failover) stay() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures, f.switching = 0, false
+}
+
+func (f *failover) endpoint() (*url.URL, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.roots[f.active], f.active == 0
+}
+
+// ActiveEndpoint returns the root url of the flyte-api endpoint requests are sent to, and whether it is the primary
+// endpoint.
+func (c client) ActiveEndpoint() (*url.URL, bool) {
+	if c.failover == nil {
+		return nil, true
+	}
+	return c.failover.endpoint()
+}
+
+// records whether a request failed, switching to another endpoint once the active one has failed too many times. The
+// switch, which reads the api links and registers the pack again, is done in the background so the failing request
+// is not held up by it
+func (c client) recordEndpointResult(failed bool) {
+	if !c.failover.record(failed) {
+		return
+	}
+	logging.OrDefault(c.logger).Warn("flyte api endpoint is failing, switching to another endpoint")
+	go c.failOver()
+}
+
+// switches to another endpoint, staying on the active one if no other is available
+func (c client) failOver() {
+	if !c.switchEndpoint(c.failover.candidates()) {
+		logging.OrDefault(c.logger).Error("cannot switch flyte api endpoint, no other endpoint is available")
+		c.failover.stay()
+	}
+}
+
+// switches back to the primary endpoint if it is healthy again
+func (c client) failBack() {
+	if !c.switchEndpoint([]int{0}) {
+		c.failover.stay()
+	}
+}
+
+// switches to the first of the endpoints whose api links can be read and that the pack can be registered with.
+// Returns false if there is none
+func (c client) switchEndpoint(candidates []int) bool {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	for _, i := range candidates {
+		root := c.failover.roots[i]
+		l, err := c.discover(getBaseURL(*root))
+		if err != nil {
+			logging.OrDefault(c.logger).Warn(fmt.Sprintf("flyte api endpoint %s is not available", redact.URL(root)), logging.KeyError, err)
+			continue
+		}
+		c.links.replace(l)
+		c.failover.switched(i)
+		c.breaker.reset()
+
+		target := "secondary"
+		if i == 0 {
+			target = "primary"
+		}
+		metrics.OrNop(c.metrics).IncCounter(metrics.ClientEndpointSwitches, metrics.Labels{"target": target})
+		logging.OrDefault(c.logger).Info(fmt.Sprintf("switched to %s flyte api endpoint %s", target, redact.URL(root)))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a flyte-api endpoint that serves the api links, pack registration and events, and can be taken down
+type fakeEndpoint struct {
+	*httptest.Server
+	mu     sync.Mutex
+	down   bool
+	delay  time.Duration // how long each response is delayed
+	packs  int
+	events int
+}
+
+func newFakeEndpoint() *fakeEndpoint {
+	f := &fakeEndpoint{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		time.Sleep(f.delay)
+		if f.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1":
+			fmt.Fprintf(w, `{"links": [{"href": "%[1]s/v1/packs", "rel": "http://example.com/swagger#!/pack/listPacks"}, {"href": "%[1]s/v1/health", "rel": "http://example.com/swagger#!/info/health"}]}`, f.URL)
+		case "/v1/packs":
+			f.packs++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"name": "Slack", "links": [{"href": "%[1]s/v1/packs/Slack/events", "rel": "http://example.com/swagger#/event"}, {"href": "%[1]s/v1/packs/Slack/actions/take", "rel": "http://example.com/swagger#!/action/takeAction"}]}`, f.URL)
+		case "/v1/packs/Slack/events":
+			f.events++
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return f
+}
+
+func (f *fakeEndpoint) setDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+func (f *fakeEndpoint) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeEndpoint) counts() (packs, events int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.packs, f.events
+}
+
+func (f *fakeEndpoint) root() *url.URL {
+	u, _ := url.Parse(f.URL)
+	return u
+}
+
+func newFailoverClient(t *testing.T, primary, secondary *fakeEndpoint, failback time.Duration) *client {
+	c := NewClient(primary.root(), 5*time.Second, WithFailover(Failover{
+		Endpoints:        []*url.URL{secondary.root()},
+		FailureThreshold: 2,
+		FailbackInterval: failback,
+	})).(*client)
+	require.NoError(t, c.CreatePack(Pack{Name: "Slack"}))
+	return c
+}
+
+// waits for the client to switch to the secondary endpoint, which is done in the background
+func requireFailedOver(t *testing.T, c *client) {
+	require.Eventually(t, func() bool {
+		_, primaryActive := c.ActiveEndpoint()
+		return !primaryActive
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func Test_PostEvent_ShouldFailOverToTheSecondaryEndpoint(t *testing.T) {
+	// given a pack registered with the primary endpoint
+	primary, secondary := newFakeEndpoint(), newFakeEndpoint()
+	defer primary.Close()
+	defer secondary.Close()
+	c := newFailoverClient(t, primary, secondary, time.Hour)
+
+	// when the primary endpoint fails as many times as the threshold
+	primary.setDown(true)
+	assert.Error(t, c.PostEvent(Event{Name: "Deployed"}))
+	assert.Error(t, c.PostEvent(Event{Name: "Deployed"}))
+
+	// then the pack is registered with the secondary endpoint, and events are sent to it
+	requireFailedOver(t, c)
+	require.NoError(t, c.PostEvent(Event{Name: "Deployed"}))
+	packs, events := secondary.counts()
+	assert.Equal(t, 1, packs)
+	assert.Equal(t, 1, events)
+	endpoint, primaryActive := c.ActiveEndpoint()
+	assert.Equal(t, secondary.URL, endpoint.String())
+	assert.False(t, primaryActive)
+	healthURL, err := c.GetFlyteHealthCheckURL()
+	require.NoError(t, err)
+	assert.Equal(t, secondary.URL+"/v1/health", healthURL.String())
+}
+
+func Test_PostEvent_ShouldFailBackToThePrimaryEndpointOnceItIsHealthy(t *testing.T) {
+	// given a client failed over to the secondary endpoint
+	primary, secondary := newFakeEndpoint(), newFakeEndpoint()
+	defer primary.Close()
+	defer secondary.Close()
+	c := newFailoverClient(t, primary, secondary, time.Millisecond)
+	primary.setDown(true)
+	c.PostEvent(Event{Name: "Deployed"})
+	c.PostEvent(Event{Name: "Deployed"})
+	requireFailedOver(t, c)
+
+	// when the primary endpoint recovers
+	primary.setDown(false)
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, c.PostEvent(Event{Name: "Deployed"}))
+
+	// then the client switches back, registering the pack again
+	assert.Eventually(t, func() bool {
+		_, primaryActive := c.ActiveEndpoint()
+		return primaryActive
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, c.PostEvent(Event{Name: "Deployed"}))
+	packs, events := primary.counts()
+	assert.Equal(t, 2, packs)
+	assert.Equal(t, 1, events)
+}
+
+func Test_PostEvent_ShouldNotWaitForTheSwitchOfEndpoint(t *testing.T) {
+	// given a secondary endpoint that is slow to register the pack
+	primary, secondary := newFakeEndpoint(), newFakeEndpoint()
+	defer primary.Close()
+	defer secondary.Close()
+	c := newFailoverClient(t, primary, secondary, time.Hour)
+	secondary.setDelay(500 * time.Millisecond)
+
+	// when the request that crosses the threshold fails
+	primary.setDown(true)
+	c.PostEvent(Event{Name: "Deployed"})
+	start := time.Now()
+	assert.Error(t, c.PostEvent(Event{Name: "Deployed"}))
+
+	// then it returns without waiting for the switch
+	assert.True(t, time.Since(start) < 250*time.Millisecond, "the request waited %s for the switch", time.Since(start))
+	requireFailedOver(t, c)
+}
+
+func Test_PostEvent_ShouldStayOnTheActiveEndpointWhenNoOtherIsAvailable(t *testing.T) {
+	primary, secondary := newFakeEndpoint(), newFakeEndpoint()
+	defer primary.Close()
+	defer secondary.Close()
+	c := newFailoverClient(t, primary, secondary, time.Hour)
+
+	primary.setDown(true)
+	secondary.setDown(true)
+	c.PostEvent(Event{Name: "Deployed"})
+	c.PostEvent(Event{Name: "Deployed"})
+	require.Eventually(t, func() bool {
+		c.failover.mu.Lock()
+		defer c.failover.mu.Unlock()
+		return !c.failover.switching
+	}, time.Second, 5*time.Millisecond)
+	primary.setDown(false)
+
+	assert.NoError(t, c.PostEvent(Event{Name: "Deployed"}))
+	_, primaryActive := c.ActiveEndpoint()
+	assert.True(t, primaryActive)
+}
+
+func TestNewClient_ShouldReadTheApiLinksOfTheSecondaryEndpointWhenThePrimaryIsDown(t *testing.T) {
+	primary, secondary := newFakeEndpoint(), newFakeEndpoint()
+	defer primary.Close()
+	defer secondary.Close()
+	primary.setDown(true)
+
+	c := newFailoverClient(t, primary, secondary, time.Hour)
+
+	packs, _ := secondary.counts()
+	assert.Equal(t, 1, packs)
+	endpoint, _ := c.ActiveEndpoint()
+	assert.Equal(t, secondary.URL, endpoint.String())
+}
+
+func TestFailover_ShouldTryTheEndpointsAfterTheActiveOneFirst(t *testing.T) {
+	root := func(s string) *url.URL {
+		u, _ := url.Parse(s)
+		return u
+	}
+	f := newFailover(root("http://a"), Failover{Endpoints: []*url.URL{root("http://b"), root("http://c")}})
+	f.switched(1)
+
+	assert.Equal(t, []int{2, 0}, f.candidates())
+	assert.Equal(t, defaultFailoverThreshold, f.settings.FailureThreshold)
+	assert.False(t, newFailover(root("http://a"), Failover{}).record(true), "a single endpoint never fails over")
+}
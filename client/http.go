@@ -18,53 +18,211 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/tracing"
+	"go.opentelemetry.io/otel/propagation"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // marshalls the body passed in into JSON then posts to the specified url, returning a http response
-// will return error if cannot marshall JSON, cannot create a http request or for a httpClient posting error
-func (c client) post(u *url.URL, body interface{}) (*http.Response, error) {
+// will return error if cannot marshall JSON, cannot create a http request or for a httpClient posting error.
+// The operation names the request in the metrics and selects its retry policy, and the trace context of ctx is sent
+// with the request
+func (c client) post(ctx context.Context, op Operation, u *url.URL, body interface{}) (*http.Response, error) {
+	return c.postWithHeader(ctx, op, u, body, nil)
+}
+
+// posts the body in the same way as post, with the headers passed in, which replace the default Content-Type. The
+// headers are kept when the request is retried. Bodies over the compression threshold are gzipped, and sent again
+// uncompressed if flyte-api responds that it does not support gzip
+func (c client) postWithHeader(ctx context.Context, op Operation, u *url.URL, body interface{}, header http.Header) (*http.Response, error) {
 	b, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot marshal body '%+v': %v", body, err)
+		return nil, fmt.Errorf("cannot marshal body '%s': %v", c.redact(body), err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewBuffer(b))
+	compressed, isCompressed := c.compressor.compress(b)
+	resp, err := c.postBytes(ctx, op, u, compressed, isCompressed, header)
+	if err != nil || !isCompressed || resp.StatusCode != http.StatusUnsupportedMediaType {
+		return resp, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return c.postBytes(ctx, op, u, b, false, header)
+}
+
+func (c client) postBytes(ctx context.Context, op Operation, u *url.URL, b []byte, gzipped bool, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewBuffer(b))
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
-	return c.httpClient.Do(req)
+	return c.do(op, req)
 }
 
 // performs a http get on the specified url, returning the http response.
 // will return error if there is a problem creating the http request or if there is a httpClient error
-func (c client) get(u *url.URL) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+func (c client) get(ctx context.Context, op Operation, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request: %v", err)
 	}
 	req.Header.Set("Accept", "application/json")
 
-	return c.httpClient.Do(req)
+	return c.do(op, req)
+}
+
+// sends the request, retrying it as the retry policy of the operation allows. The response of the last attempt is
+// returned
+func (c client) do(op Operation, req *http.Request) (*http.Response, error) {
+	policy := c.retries[op]
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(op, req)
+		wait, retry := policy.retry(op, attempt, req, resp, err, c.idempotencySupported())
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.OrNop(c.metrics).IncCounter(metrics.ClientRetries, metrics.Labels{"endpoint": string(op)})
+		logging.OrDefault(c.logger).Warn(fmt.Sprintf("retrying %s request to flyte api in %v", op, wait),
+			logging.KeyError, describeFailure(resp, err))
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// the cause of a failed attempt for the log
+func describeFailure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+// returns a copy of the request to send again, with its body reset
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.Body == nil || req.GetBody == nil {
+		return r, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("cannot send request again: %v", err)
+	}
+	r.Body = body
+	return r, nil
+}
+
+// sends the request with the traceparent of its context, recording the request count and latency by endpoint and http status.
+// Gzip encoded responses are decompressed. Fails fast with ErrCircuitOpen if the circuit breaker is open. While failed
+// over to a secondary endpoint, the primary one is checked in the background once the failback interval has passed, and
+// expired links are refreshed in the background
+func (c client) send(op Operation, req *http.Request) (*http.Response, error) {
+	endpoint := string(op)
+	m := metrics.OrNop(c.metrics)
+	if c.failover.failbackDue() {
+		go c.failBack()
+	}
+	c.refreshExpiredLinks()
+	generation, err := c.breaker.allow()
+	if err != nil {
+		m.IncCounter(metrics.ClientCircuitRejected, metrics.Labels{"endpoint": endpoint})
+		c.recordEndpointResult(true)
+		return nil, err
+	}
+
+	tracing.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	req.Header.Set("Accept-Encoding", "gzip")
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	c.recordResult(generation, req, resp, err)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		c.compressor.observe(resp)
+		decompress(resp)
+	}
+	labels := metrics.Labels{"endpoint": endpoint, "status": status}
+	m.IncCounter(metrics.ClientRequests, labels)
+	m.ObserveDuration(metrics.ClientRequestDuration, labels, time.Since(start))
+	return resp, err
+}
+
+// records the result of a request in the circuit breaker and failover. Connection errors, timeouts and 5xx responses
+// are failures, requests cancelled by the caller are neither a failure nor a success
+func (c client) recordResult(generation int, req *http.Request, resp *http.Response, err error) {
+	if err != nil && req.Context().Err() != nil {
+		c.breaker.release(generation)
+		return
+	}
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	c.breaker.record(generation, failed)
+	c.recordEndpointResult(failed)
+}
+
+// records changes of the circuit breaker state in the metrics and log
+func circuitChanged(m metrics.Metrics, l logging.Logger) func(CircuitState) {
+	m = metrics.OrNop(m)
+	return func(s CircuitState) {
+		metrics.SetGauge(m, metrics.ClientCircuitState, nil, float64(s))
+		m.IncCounter(metrics.ClientCircuitTransitions, metrics.Labels{"state": s.String()})
+		logger := logging.OrDefault(l)
+		switch s {
+		case CircuitOpen:
+			logger.Warn("flyte api circuit breaker opened, requests fail fast until it is probed again")
+		case CircuitHalfOpen:
+			logger.Info("flyte api circuit breaker half-open, probing flyte api")
+		case CircuitClosed:
+			logger.Info("flyte api circuit breaker closed")
+		}
+	}
 }
 
 // gets a struct from the specified url and deserialises it into the supplied interface
-// will return error if there is a problem getting the struct or if it cannot deserialise into the supplied interface
-func (c *client) getStruct(u *url.URL, s interface{}) error {
-	resp, err := c.get(u)
+// will return error if there is a problem getting the struct or if it cannot deserialise into the supplied interface,
+// wrapping ErrResponseTooLarge if the response is larger than the maximum response size
+func (c *client) getStruct(ctx context.Context, op Operation, u *url.URL, s interface{}) error {
+	resp, err := c.get(ctx, op, u)
 	if err != nil {
-		return fmt.Errorf("error getting url %q: %s", u.String(), err)
+		return fmt.Errorf("error getting url %q: %w", u.String(), err)
 	}
 	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(s)
+	err = c.decode(resp, s)
 	if err != nil {
-		return fmt.Errorf("could not deserialise response from %q: %s", u.String(), err)
+		return fmt.Errorf("could not deserialise response from %q: %w", u.String(), err)
 	}
 	return nil
 }
+
+// deserialises the JSON response body into v, reading no more than the maximum response size
+func (c client) decode(resp *http.Response, v interface{}) error {
+	return json.NewDecoder(limitBody(resp.Body, c.maxBodySize)).Decode(v)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// IdempotencyKeyHeader is sent with events and action results, so flyte-api can recognise a request it has already
+// processed, e.g. when the response was lost and the request is retried.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// the rel of the link flyte-api advertises support for the Idempotency-Key header with
+const idempotencyRel = "idempotency"
+
+// whether flyte-api recognises the Idempotency-Key header, so events and action results can be retried whatever the
+// failure: it is set in the options, or advertised on the api links or the registered pack
+func (c client) idempotencySupported() bool {
+	if c.idempotency {
+		return true
+	}
+	var packLinks []Link
+	if p := c.registered(); p != nil {
+		packLinks = p.Links
+	}
+	for _, l := range [][]Link{packLinks, c.api()["links"]} {
+		if _, err := newLinkRegistry(nil, l, nil).find(idempotencyRel); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEventID returns a random, version 4 UUID for Event.ID. Use it to give an event its ID before storing it, so the
+// ID stays the same if the event is sent again.
+func NewEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand only fails if the system source of randomness is unavailable
+		panic(fmt.Sprintf("cannot generate event id: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestNewEventID_ShouldReturnRandomUUIDs(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	id := NewEventID()
+
+	assert.Regexp(t, uuid, id)
+	assert.NotEqual(t, id, NewEventID())
+}
+
+func Test_PostEvent_ShouldSendTheCallersKey(t *testing.T) {
+	// given
+	ts, rec := mockServerWithRecorder(http.StatusAccepted, "")
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when the caller sets the id, e.g. to the id of the message the event was observed from
+	err := c.PostEvent(Event{ID: "msg-1234", Name: "Deployed"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1234", rec.reqs[0].Header.Get(IdempotencyKeyHeader))
+	var got Event
+	require.NoError(t, json.Unmarshal(rec.body[0], &got))
+	assert.Equal(t, "msg-1234", got.ID)
+}
+
+func Test_CompleteAction_ShouldKeepTheIdempotencyKey_WhenRetried(t *testing.T) {
+	// given flyte-api times out processing the first attempt
+	var keys, ids []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		b, _ := ioutil.ReadAll(r.Body)
+		var e Event
+		json.Unmarshal(b, &e)
+		ids = append(ids, e.ID)
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	// and flyte-api supports the Idempotency-Key header
+	c := newTestClient(ts.URL, t)
+	o := newOptions([]Option{WithRetryPolicy(noWait), WithIdempotencySupport()})
+	c.retries, c.idempotency = o.retries, o.idempotency
+	resultURL, _ := url.Parse(ts.URL + "/actionResult")
+
+	// when
+	err := c.CompleteAction(Action{Links: []Link{{Href: resultURL, Rel: "actionResult"}}}, Event{Name: "Deployed"})
+
+	// then the retry has the same key, so flyte-api does not record the result twice
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, []string{keys[0], keys[0]}, ids)
+}
+
+func Test_PostEvent_ShouldNotRetryWhenFlyteApiMayHaveProcessedIt_UnlessIdempotencyIsSupported(t *testing.T) {
+	for name, tc := range map[string]struct {
+		advertised bool
+		requests   int
+	}{
+		"not supported": {advertised: false, requests: 1},
+		"advertised":    {advertised: true, requests: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given flyte-api times out processing the first attempt
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					w.WriteHeader(http.StatusGatewayTimeout)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer ts.Close()
+
+			c := newTestClient(ts.URL, t)
+			c.retries = newOptions([]Option{WithRetryPolicy(noWait)}).retries
+			c.eventsURL, _ = url.Parse(ts.URL + "/events")
+			if tc.advertised {
+				idempotency, _ := url.Parse(ts.URL + "/idempotency")
+				c.setAPI(map[string][]Link{"links": {{Href: idempotency, Rel: "http://example.com/rels/idempotency"}}})
+			}
+
+			// when
+			c.PostEvent(Event{Name: "Deployed"})
+
+			// then
+			assert.Equal(t, tc.requests, requests)
+		})
+	}
+}
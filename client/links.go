@@ -0,0 +1,216 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// the links of the flyte-api endpoint in use. They are shared between copies of the client, and replaced together, so
+// requests made at the same time never see some of the links of a refresh and not others
+type links struct {
+	mu            sync.RWMutex
+	baseURL       *url.URL
+	apiLinks      map[string][]Link
+	eventsURL     *url.URL
+	takeActionURL *url.URL
+	pack          *Pack         // the pack definition registered, registered again when the links are discovered again
+	encoding      EventEncoding // how events are sent to the registered pack links
+	discovered    time.Time     // when the api links were read
+	refreshing    bool          // a refresh of the links has been started in the background
+
+	refreshMu sync.Mutex // held while the links are discovered again, so only one request does it at a time
+}
+
+func (l *links) base() *url.URL {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.baseURL
+}
+
+func (l *links) api() map[string][]Link {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.apiLinks
+}
+
+func (l *links) events() *url.URL {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.eventsURL
+}
+
+func (l *links) takeAction() *url.URL {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.takeActionURL
+}
+
+func (l *links) registered() *Pack {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.pack
+}
+
+// the registered pack name, the source of events that do not set one
+func (l *links) source() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.pack == nil {
+		return ""
+	}
+	return l.pack.Name
+}
+
+// the encoding negotiated when the pack was registered, empty if it has not been
+func (l *links) negotiatedEncoding() EventEncoding {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.encoding
+}
+
+func (l *links) discoveredAt() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.discovered
+}
+
+func (l *links) setAPI(apiLinks map[string][]Link) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.apiLinks, l.discovered = apiLinks, time.Now()
+}
+
+// sets the links of the registered pack, its definition and the encoding events are sent in
+func (l *links) setPack(eventsURL, takeActionURL *url.URL, pack Pack, encoding EventEncoding) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.eventsURL, l.takeActionURL, l.pack, l.encoding = eventsURL, takeActionURL, &pack, encoding
+}
+
+// replaces every link with those discovered again
+func (l *links) replace(other *links) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.baseURL, l.apiLinks, l.discovered = other.baseURL, other.apiLinks, other.discovered
+	l.eventsURL, l.takeActionURL, l.pack, l.encoding = other.eventsURL, other.takeActionURL, other.pack, other.encoding
+}
+
+// returns true when the links are older than the ttl and the caller must refresh them, then call refreshed. A ttl of
+// 0 means the links do not expire
+func (l *links) refreshDue(ttl time.Duration) bool {
+	if l == nil || ttl <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.refreshing || l.discovered.IsZero() || time.Since(l.discovered) < ttl {
+		return false
+	}
+	l.refreshing = true
+	return true
+}
+
+func (l *links) refreshed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshing = false
+}
+
+// refreshes the links in the background once they are older than the link ttl
+func (c client) refreshExpiredLinks() {
+	if !c.links.refreshDue(c.linkTTL) {
+		return
+	}
+	go func() {
+		defer c.links.refreshed()
+		if err := c.refreshLinks(time.Now()); err != nil {
+			logging.OrDefault(c.logger).Warn("cannot refresh flyte api links", logging.KeyError, err)
+		}
+	}()
+}
+
+// reads the api links of the endpoint in use again, and registers the pack again if it has been registered. Links
+// discovered after since are kept, as another request has already refreshed them
+func (c client) refreshLinks(since time.Time) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.discoveredAt().After(since) {
+		return nil
+	}
+	base := c.base()
+	if base == nil {
+		return errors.New("the flyte api url is not known")
+	}
+	l, err := c.discover(base)
+	if err != nil {
+		return err
+	}
+	c.links.replace(l)
+	logging.OrDefault(c.logger).Debug("refreshed flyte api links")
+	return nil
+}
+
+// reads the api links at the base url, and registers the pack if one has been registered. The requests are not
+// retried, nor counted by the circuit breaker or failover, so a failing endpoint is not retried while it is probed
+func (c client) discover(base *url.URL) (*links, error) {
+	probe := c
+	probe.links = &links{baseURL: base}
+	probe.breaker, probe.failover, probe.retries, probe.linkTTL = nil, nil, nil, 0
+
+	var apiLinks map[string][]Link
+	if err := probe.getStruct(context.Background(), OperationAPILinks, base, &apiLinks); err != nil {
+		return nil, err
+	}
+	probe.setAPI(apiLinks)
+	if pack := c.registered(); pack != nil {
+		if err := probe.CreatePack(*pack); err != nil {
+			return nil, err
+		}
+	}
+	return probe.links, nil
+}
+
+// whether a cached link has moved or gone, so the links must be discovered again
+func isStale(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone
+}
+
+// discovers the links again, registering the pack again, after a request to a cached link sent at since responded 404
+// or 410. Returns the link read by get, to send the request to again, nil if the flyte api url is not known so the
+// links cannot be discovered, or an error if discovering them failed
+func (c client) rediscover(since time.Time, old *url.URL, get func(*links) *url.URL) (*url.URL, error) {
+	if c.base() == nil {
+		return nil, nil
+	}
+	if err := c.refreshLinks(since); err != nil {
+		logging.OrDefault(c.logger).Warn(fmt.Sprintf("cannot discover flyte api links again after %s was not found", old), logging.KeyError, err)
+		return nil, err
+	}
+	return get(c.links), nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a flyte-api that issues new pack links each time it is redeployed, responding 404 to the links issued before
+func redeployableApi() (*httptest.Server, func()) {
+	var mu sync.Mutex
+	deployment := 1
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		current := fmt.Sprintf("/v1/packs/Slack/%d", deployment)
+		switch {
+		case r.URL.Path == "/v1":
+			fmt.Fprintf(w, `{"links": [{"href": "%s/v1/packs", "rel": "http://example.com/swagger#!/pack/listPacks"}]}`, ts.URL)
+		case r.URL.Path == "/v1/packs":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"name": "Slack", "links": [{"href": "%[1]s%[2]s/events", "rel": "http://example.com/swagger#/event"}, {"href": "%[1]s%[2]s/actions/take", "rel": "http://example.com/swagger#!/action/takeAction"}]}`, ts.URL, current)
+		case r.URL.Path == current+"/events":
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == current+"/actions/take":
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/actions/take"):
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return ts, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		deployment++
+	}
+}
+
+func newRegisteredClient(t *testing.T, serverURL string, opts ...Option) *client {
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	c := NewClient(u, 5*time.Second, opts...).(*client)
+	require.NoError(t, c.CreatePack(Pack{Name: "Slack"}))
+	return c
+}
+
+func Test_PostEvent_ShouldDiscoverTheLinksAgainWhenTheEventsURLIsNotFound(t *testing.T) {
+	// given
+	ts, redeploy := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when
+	redeploy()
+	err := c.PostEvent(Event{Name: "Deployed"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/2/events", c.events().String())
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/2/actions/take", c.takeAction().String())
+}
+
+func Test_TakeAction_ShouldDiscoverTheLinksAgainWhenTheTakeActionURLIsGone(t *testing.T) {
+	// given
+	ts, redeploy := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when
+	redeploy()
+	action, err := c.TakeAction()
+
+	// then
+	require.NoError(t, err)
+	assert.Nil(t, action)
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/2/actions/take", c.takeAction().String())
+}
+
+func Test_TakeAction_ShouldReturnARetryableErrorWhenTheLinksCannotBeDiscoveredAgain(t *testing.T) {
+	// given
+	ts, redeploy := redeployableApi()
+	c := newRegisteredClient(t, ts.URL)
+	redeploy()
+	ts.Close()
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer gone.Close()
+	c.takeActionURL, _ = url.Parse(gone.URL + "/v1/packs/Slack/1/actions/take")
+
+	// when
+	_, err := c.TakeAction()
+
+	// then
+	require.Error(t, err)
+	assert.NotEqual(t, reflect.TypeOf(NotFoundError{}), reflect.TypeOf(err))
+	assert.Contains(t, err.Error(), "it was not found and the links cannot be discovered again")
+}
+
+func Test_TakeAction_ShouldReturnNotFoundErrorWhenStillNotFoundOnceThePackIsRegisteredAgain(t *testing.T) {
+	// given a flyte-api that registers the pack, but does not know its take action url
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1":
+			w.Write([]byte(`{"links": [{"href": "/v1/packs", "rel": "pack/listPacks"}]}`))
+		case "/v1/packs":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"name": "Slack", "links": [{"href": "/v1/packs/Slack/events", "rel": "event"}, {"href": "/v1/packs/Slack/actions/take", "rel": "takeAction"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when
+	_, err := c.TakeAction()
+
+	// then
+	require.IsType(t, NotFoundError{}, err)
+}
+
+func Test_TakeAction_ShouldReturnARetryableErrorWhenStillGoneOnceThePackIsRegisteredAgain(t *testing.T) {
+	// given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1":
+			w.Write([]byte(`{"links": [{"href": "/v1/packs", "rel": "pack/listPacks"}]}`))
+		case "/v1/packs":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"name": "Slack", "links": [{"href": "/v1/packs/Slack/events", "rel": "event"}, {"href": "/v1/packs/Slack/actions/take", "rel": "takeAction"}]}`))
+		default:
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when
+	_, err := c.TakeAction()
+
+	// then
+	require.Error(t, err)
+	assert.NotEqual(t, reflect.TypeOf(NotFoundError{}), reflect.TypeOf(err))
+}
+
+func Test_PostEvent_ShouldRefreshTheLinksOnceTheyExpire(t *testing.T) {
+	// given
+	ts, redeploy := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL, WithLinkTTL(time.Millisecond))
+
+	// when
+	redeploy()
+	time.Sleep(2 * time.Millisecond)
+	c.PostEvent(Event{Name: "Deployed"})
+
+	// then
+	assert.Eventually(t, func() bool {
+		return c.events().String() == ts.URL+"/v1/packs/Slack/2/events"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func Test_PostEvent_ShouldUseTheLinksDiscoveredAgainByAnotherRequest(t *testing.T) {
+	ts, redeploy := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL, WithLinkTTL(time.Hour))
+
+	redeploy()
+	c.TakeAction()
+	discovered := c.discoveredAt()
+	err := c.PostEvent(Event{Name: "Deployed"})
+
+	require.NoError(t, err)
+	assert.Equal(t, discovered, c.discoveredAt())
+}
+
+func Test_RefreshLinks_ShouldReplaceTheLinksWhileRequestsAreMade(t *testing.T) {
+	// given
+	ts, redeploy := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when requests are made while the links are refreshed
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- c.PostEvent(Event{Name: "Deployed"})
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.TakeAction()
+			errs <- err
+		}()
+	}
+	redeploy()
+	require.NoError(t, c.refreshLinks(time.Now()))
+	wg.Wait()
+	close(errs)
+
+	// then every request succeeds, using either the links before or after the refresh
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func Test_CreatePack_ShouldRegisterAgainWhileEventsAreSent(t *testing.T) {
+	// given
+	ts, _ := redeployableApi()
+	defer ts.Close()
+	c := newRegisteredClient(t, ts.URL)
+
+	// when the pack is registered again while events are sent
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.PostEvent(Event{Name: "Deployed"})
+		}()
+	}
+	require.NoError(t, c.CreatePack(Pack{Name: "Slack"}))
+	wg.Wait()
+	close(errs)
+
+	// then
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "Slack", c.source())
+}
@@ -0,0 +1,196 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"github.com/ExpediaGroup/flyte-client/config"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option customises a client created with NewClient or NewInsecureClient.
+type Option func(*options)
+
+type options struct {
+	jwt             string
+	jwtSet          bool // whether WithJWT was passed, otherwise the FLYTE_JWT environment variable is used
+	tlsConfig       *tls.Config
+	metrics         metrics.Metrics
+	logger          logging.Logger
+	redactor        redact.Redactor
+	proxy           func(*http.Request) (*url.URL, error)
+	transport       Transport
+	breaker         CircuitBreaker
+	failover        Failover
+	retries         map[Operation]RetryPolicy
+	encoding        EventEncoding
+	idempotency     bool
+	compression     Compression
+	maxResponseSize int64
+	linkTTL         time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{proxy: http.ProxyFromEnvironment}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.jwtSet {
+		o.jwt = config.GetJWT()
+	}
+	return o
+}
+
+// WithJWT sends the token as a bearer token with every request, instead of the FLYTE_JWT environment variable. An
+// empty token sends none. Pass the config.Values JWT when the client is configured from config.Load, so it is read
+// from the same source as the rest of the configuration.
+func WithJWT(token string) Option {
+	return func(o *options) {
+		o.jwt, o.jwtSet = token, true
+	}
+}
+
+// WithTLSConfig uses the TLS configuration passed in when connecting to the flyte api, e.g. for custom CA
+// certificates or client certificates.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithMetrics records the count and latency of requests to the flyte api by endpoint and http status.
+func WithMetrics(m metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithLogger logs to l instead of the default logger.
+func WithLogger(l logging.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithRedactor describes events and packs in error messages using r, instead of the default redaction policy.
+func WithRedactor(r redact.Redactor) Option {
+	return func(o *options) {
+		o.redactor = r
+	}
+}
+
+// WithProxy connects to the flyte api through the proxy, instead of the proxy set by the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables. Credentials in the proxy URL are sent to the proxy. Hosts matching an entry of
+// noProxy are connected to directly: "*" matches every host, and entries can be IP addresses, CIDR ranges or host
+// names, which also match their subdomains. A nil proxyURL connects directly to every host.
+func WithProxy(proxyURL *url.URL, noProxy ...string) Option {
+	return func(o *options) {
+		o.proxy = proxyFunc(proxyURL, noProxy)
+	}
+}
+
+// WithRetryPolicy retries the operations passed in with the policy, or every operation if none are passed in.
+// Later options replace the policy of an operation set by earlier ones, e.g. to retry every operation except
+// TakeAction:
+//
+//	client.WithRetryPolicy(client.DefaultRetryPolicy()),
+//	client.WithRetryPolicy(client.RetryPolicy{}, client.OperationTakeAction),
+//
+// Requests are not retried by default.
+func WithRetryPolicy(p RetryPolicy, operations ...Operation) Option {
+	if len(operations) == 0 {
+		operations = []Operation{OperationAPILinks, OperationCreatePack, OperationPostEvent, OperationTakeAction, OperationCompleteAction}
+	}
+	return func(o *options) {
+		if o.retries == nil {
+			o.retries = make(map[Operation]RetryPolicy)
+		}
+		for _, op := range operations {
+			o.retries[op] = p.withDefaults()
+		}
+	}
+}
+
+// WithIdempotencySupport tells the client flyte-api recognises the Idempotency-Key header of events and action
+// results, so they are retried whatever the failure, as the retry policy allows. Without it they are only retried
+// when flyte-api cannot have processed them, unless flyte-api advertises support with an "idempotency" link.
+func WithIdempotencySupport() Option {
+	return func(o *options) {
+		o.idempotency = true
+	}
+}
+
+// WithEventEncoding sends events and action results in the encoding passed in, e.g. as CloudEvents. Events are sent in
+// the flyte format by default.
+func WithEventEncoding(e EventEncoding) Option {
+	return func(o *options) {
+		o.encoding = e
+	}
+}
+
+// WithCircuitBreaker stops the client sending requests to flyte-api while it is failing, so requests fail fast with
+// ErrCircuitOpen rather than waiting for the timeout. The circuit breaker is disabled by default.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(o *options) {
+		o.breaker = cb
+	}
+}
+
+// WithFailover switches to the secondary flyte-api endpoints passed in while the endpoint the client was created with
+// is failing, and back once it has recovered. The client only uses the endpoint it was created with by default.
+func WithFailover(f Failover) Option {
+	return func(o *options) {
+		o.failover = f
+	}
+}
+
+// WithLinkTTL discovers the flyte-api links again, and registers the pack again, once they are older than ttl. Links
+// are also discovered again when flyte-api responds 404 or 410 to the events or take action url. Links do not expire
+// by default.
+func WithLinkTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.linkTTL = ttl
+	}
+}
+
+// WithTransport tunes the connections to the flyte api, e.g. the dial timeout or idle connections per host.
+func WithTransport(t Transport) Option {
+	return func(o *options) {
+		o.transport = t
+	}
+}
+
+// WithCompression gzips request bodies of at least c.Threshold bytes, once flyte-api has advertised that it accepts
+// gzip requests. Requests are not compressed by default. Gzip encoded responses are always decompressed.
+func WithCompression(c Compression) Option {
+	return func(o *options) {
+		o.compression = c
+	}
+}
+
+// WithMaxResponseSize fails requests whose response body is larger than n bytes with ErrResponseTooLarge, rather than
+// reading it into memory. Response bodies are not limited by default.
+func WithMaxResponseSize(n int64) Option {
+	return func(o *options) {
+		o.maxResponseSize = n
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrLinkNotFound and ErrAmbiguousLink are wrapped by the errors returned when a link relation the client needs is
+// missing, or matches links with different hrefs. Check for them with errors.Is.
+var (
+	ErrLinkNotFound  = errors.New("link relation not found")
+	ErrAmbiguousLink = errors.New("link relation is ambiguous")
+)
+
+// LinkError reports a link relation that is missing from the links returned by flyte-api, or that matches several of
+// them with different hrefs.
+type LinkError struct {
+	Rel     string
+	Links   []Link // the links searched
+	Matches []Link // the links matched by an ambiguous relation
+}
+
+func (e *LinkError) Error() string {
+	if len(e.Matches) > 1 {
+		return fmt.Sprintf("link with rel %q is ambiguous, it matches %s", e.Rel, formatLinks(e.Matches))
+	}
+	return fmt.Sprintf("could not find link with rel %q in %s", e.Rel, formatLinks(e.Links))
+}
+
+func (e *LinkError) Unwrap() error {
+	if len(e.Matches) > 1 {
+		return ErrAmbiguousLink
+	}
+	return ErrLinkNotFound
+}
+
+// formats the links as '[{href rel} ...]'
+func formatLinks(links []Link) string {
+	s := make([]string, len(links))
+	for i, l := range links {
+		href := "<nil>"
+		if l.Href != nil {
+			href = l.Href.String()
+		}
+		if l.Templated {
+			href = l.hrefTemplate()
+		}
+		s[i] = fmt.Sprintf("{%s %s}", href, l.Rel)
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// linkRegistry finds links by relation. A relation matches a link whose rel is exactly the same, or, if there is
+// none, whose rel ends with the same path segments once its namespace is removed: "takeAction" and "action/takeAction"
+// both match "http://example.com/swagger#!/action/takeAction", but "takeAction" does not match "untakeAction".
+// Fully qualified relations, e.g. "http://example.com/rels/event" or "flyte:event", only match exactly. Relative hrefs
+// are resolved against base, the url of the document the links were read from, and templated hrefs are expanded with
+// vars.
+type linkRegistry struct {
+	base  *url.URL
+	links []Link
+	vars  map[string]string
+}
+
+func newLinkRegistry(base *url.URL, links []Link, vars map[string]string) linkRegistry {
+	return linkRegistry{base: base, links: links, vars: vars}
+}
+
+// returns the url of the link with the relation, or a *LinkError if there is none or the relation is ambiguous
+func (r linkRegistry) find(rel string) (*url.URL, error) {
+	var exact, namespaced []Link
+	want := relSegments(rel)
+	qualified := isQualifiedRel(rel)
+	for _, l := range r.links {
+		switch {
+		case l.Rel == rel:
+			exact = append(exact, l)
+		case !qualified && hasSegmentSuffix(relSegments(l.Rel), want):
+			namespaced = append(namespaced, l)
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = namespaced
+	}
+	if len(matches) == 0 {
+		return nil, &LinkError{Rel: rel, Links: r.links}
+	}
+
+	var found *url.URL
+	var distinct []Link
+	for _, l := range matches {
+		u, err := r.resolve(l)
+		if err != nil {
+			return nil, fmt.Errorf("link with rel %q: %w", l.Rel, err)
+		}
+		if found == nil || u.String() != found.String() {
+			if found == nil {
+				found = u
+			}
+			distinct = append(distinct, l)
+		}
+	}
+	if len(distinct) > 1 {
+		return nil, &LinkError{Rel: rel, Links: r.links, Matches: distinct}
+	}
+	return found, nil
+}
+
+// the absolute url of the link, with its template expanded
+func (r linkRegistry) resolve(l Link) (*url.URL, error) {
+	u := l.Href
+	if l.Templated {
+		expanded, err := expandTemplate(l.hrefTemplate(), r.vars)
+		if err != nil {
+			return nil, err
+		}
+		if u, err = url.Parse(expanded); err != nil {
+			return nil, fmt.Errorf("expanded href %q is not a valid url: %v", expanded, err)
+		}
+	}
+	if u == nil {
+		return nil, errors.New("link has no href")
+	}
+	if r.base != nil && !u.IsAbs() {
+		u = r.base.ResolveReference(u)
+	}
+	return u, nil
+}
+
+// whether the relation is a URI or CURIE, which is only matched exactly
+func isQualifiedRel(rel string) bool {
+	u, err := url.Parse(rel)
+	return err == nil && u.Scheme != ""
+}
+
+// the path segments of a relation, without its namespace: the fragment of a URI relation, e.g.
+// "http://example.com/swagger#!/pack/listPacks" is "pack/listPacks", and the reference of a CURIE e.g. "flyte:event"
+func relSegments(rel string) []string {
+	s := rel
+	if u, err := url.Parse(rel); err == nil && u.Scheme != "" {
+		switch {
+		case u.Fragment != "":
+			s = strings.TrimPrefix(u.Fragment, "!")
+		case u.Opaque != "":
+			s = u.Opaque
+		default:
+			s = u.Path
+		}
+	}
+	var segments []string
+	for _, seg := range strings.Split(s, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+func hasSegmentSuffix(segments, suffix []string) bool {
+	if len(suffix) == 0 || len(suffix) > len(segments) {
+		return false
+	}
+	offset := len(segments) - len(suffix)
+	for i, s := range suffix {
+		if segments[offset+i] != s {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func link(href, rel string) Link {
+	u, _ := url.Parse(href)
+	return Link{Href: u, Rel: rel}
+}
+
+func Test_LinkRegistry_ShouldNotMatchARelationThatOnlySharesASuffix(t *testing.T) {
+	// given
+	links := []Link{
+		link("http://example.com/untake", "http://example.com/swagger#!/action/untakeAction"),
+		link("http://example.com/take", "http://example.com/swagger#!/action/takeAction"),
+	}
+
+	// when
+	u, err := newLinkRegistry(nil, links, nil).find("takeAction")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/take", u.String())
+}
+
+func Test_LinkRegistry_ShouldMatchNamespacedRelationsByPathSegments(t *testing.T) {
+	// given
+	links := []Link{link("http://example.com/packs", "http://example.com/swagger#!/pack/listPacks")}
+	registry := newLinkRegistry(nil, links, nil)
+
+	// when
+	bySegment, segmentErr := registry.find("listPacks")
+	byPath, pathErr := registry.find("pack/listPacks")
+	_, partialErr := registry.find("ack/listPacks")
+
+	// then
+	require.NoError(t, segmentErr)
+	require.NoError(t, pathErr)
+	assert.Equal(t, "http://example.com/packs", bySegment.String())
+	assert.Equal(t, "http://example.com/packs", byPath.String())
+	assert.True(t, errors.Is(partialErr, ErrLinkNotFound))
+}
+
+func Test_LinkRegistry_ShouldPreferAnExactMatch(t *testing.T) {
+	// given
+	links := []Link{
+		link("http://example.com/namespaced", "http://example.com/rels/event"),
+		link("http://example.com/exact", "event"),
+	}
+
+	// when
+	u, err := newLinkRegistry(nil, links, nil).find("event")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/exact", u.String())
+}
+
+func Test_LinkRegistry_ShouldOnlyMatchQualifiedRelationsExactly(t *testing.T) {
+	// given
+	links := []Link{
+		link("http://example.com/a", "http://example.com/rels/event"),
+		link("http://example.com/b", "http://other.com/rels/event"),
+		link("http://example.com/c", "flyte:event"),
+	}
+	registry := newLinkRegistry(nil, links, nil)
+
+	// when
+	uri, uriErr := registry.find("http://other.com/rels/event")
+	curie, curieErr := registry.find("flyte:event")
+	_, missingErr := registry.find("http://another.com/rels/event")
+
+	// then
+	require.NoError(t, uriErr)
+	require.NoError(t, curieErr)
+	assert.Equal(t, "http://example.com/b", uri.String())
+	assert.Equal(t, "http://example.com/c", curie.String())
+	assert.True(t, errors.Is(missingErr, ErrLinkNotFound))
+}
+
+func Test_LinkRegistry_ShouldReportAnAmbiguousRelation(t *testing.T) {
+	// given
+	links := []Link{
+		link("http://example.com/a", "http://example.com/rels/event"),
+		link("http://example.com/b", "http://other.com/rels/event"),
+	}
+
+	// when
+	_, err := newLinkRegistry(nil, links, nil).find("event")
+
+	// then
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrAmbiguousLink))
+	assert.EqualError(t, err, `link with rel "event" is ambiguous, it matches [{http://example.com/a http://example.com/rels/event} {http://example.com/b http://other.com/rels/event}]`)
+	var linkErr *LinkError
+	require.True(t, errors.As(err, &linkErr))
+	assert.Equal(t, "event", linkErr.Rel)
+	assert.Len(t, linkErr.Matches, 2)
+}
+
+func Test_LinkRegistry_ShouldNotReportMatchesWithTheSameHrefAsAmbiguous(t *testing.T) {
+	// given
+	links := []Link{
+		link("http://example.com/events", "http://example.com/rels/event"),
+		link("/events", "http://other.com/rels/event"),
+	}
+	base, _ := url.Parse("http://example.com/packs")
+
+	// when
+	u, err := newLinkRegistry(base, links, nil).find("event")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/events", u.String())
+}
+
+func Test_LinkRegistry_ShouldReportAMissingRelation(t *testing.T) {
+	// given
+	links := []Link{link("http://example.com/a", "untakeAction")}
+
+	// when
+	_, err := newLinkRegistry(nil, links, nil).find("takeAction")
+
+	// then
+	assert.True(t, errors.Is(err, ErrLinkNotFound))
+	assert.EqualError(t, err, `could not find link with rel "takeAction" in [{http://example.com/a untakeAction}]`)
+}
+
+func Test_LinkRegistry_ShouldResolveRelativeHrefsAgainstTheBaseURL(t *testing.T) {
+	// given
+	links := []Link{
+		link("/v1/packs/Slack/events", "event"),
+		link("actions/take", "takeAction"),
+	}
+	base, _ := url.Parse("http://example.com/v1/packs/")
+	registry := newLinkRegistry(base, links, nil)
+
+	// when
+	events, eventsErr := registry.find("event")
+	take, takeErr := registry.find("takeAction")
+
+	// then
+	require.NoError(t, eventsErr)
+	require.NoError(t, takeErr)
+	assert.Equal(t, "http://example.com/v1/packs/Slack/events", events.String())
+	assert.Equal(t, "http://example.com/v1/packs/actions/take", take.String())
+}
+
+func Test_LinkRegistry_ShouldExpandTemplatedHrefs(t *testing.T) {
+	// given
+	var links []Link
+	err := json.Unmarshal([]byte(`[{"href": "/v1/packs/{packName}/actions/take{?timeout}", "rel": "takeAction", "templated": true}]`), &links)
+	require.NoError(t, err)
+	base, _ := url.Parse("http://example.com")
+
+	// when
+	u, err := newLinkRegistry(base, links, templateVars("Slack Bot")).find("takeAction")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/v1/packs/Slack%20Bot/actions/take", u.String())
+}
+
+func Test_LinkRegistry_ShouldReportAnInvalidTemplate(t *testing.T) {
+	// given
+	links := []Link{{Href: &url.URL{Path: "/v1/packs/{packName"}, Rel: "event", Templated: true}}
+
+	// when
+	_, err := newLinkRegistry(nil, links, nil).find("event")
+
+	// then
+	assert.EqualError(t, err, `link with rel "event": unclosed expression in uri template "/v1/packs/{packName"`)
+}
+
+func Test_CreatePack_ShouldResolveRelativeAndTemplatedPackLinks(t *testing.T) {
+	// given
+	ts := mockServer(http.StatusCreated, `{"name": "Slack", "links": [
+		{"href": "/v1/packs/{packName}/events", "rel": "http://example.com/swagger#/event", "templated": true},
+		{"href": "/v1/packs/Slack/actions/untake", "rel": "http://example.com/swagger#!/action/untakeAction"},
+		{"href": "/v1/packs/Slack/actions/take", "rel": "http://example.com/swagger#!/action/takeAction"}
+	]}`)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+
+	// when
+	err := c.CreatePack(Pack{Name: "Slack"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/events", c.events().String())
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/actions/take", c.takeAction().String())
+}
+
+func Test_Link_ShouldRoundTripATemplatedHref(t *testing.T) {
+	// given
+	in := `{"href":"/v1/packs/{packName}{?name,timeout}","rel":"takeAction","templated":true}`
+
+	// when
+	var l Link
+	require.NoError(t, json.Unmarshal([]byte(in), &l))
+	out, err := json.Marshal(l)
+
+	// then
+	require.NoError(t, err)
+	assert.JSONEq(t, in, string(out))
+}
+
+func Test_TakeAction_ShouldResolveTheActionResultURLAgainstTheTakeActionURL(t *testing.T) {
+	// given a server returning an action with a relative result link
+	ts := mockServer(http.StatusOK, `{"command": "deploy", "links": [{"href": "1/result", "rel": "http://example.com/swagger#!/action/actionResult"}]}`)
+	defer ts.Close()
+	c := newTestClient(ts.URL, t)
+	u, _ := url.Parse(ts.URL + "/v1/packs/Slack/actions/")
+	c.takeActionURL = u
+
+	// when
+	action, err := c.TakeAction()
+	require.NoError(t, err)
+	resultURL, err := action.ResultURL()
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/v1/packs/Slack/actions/1/result", resultURL.String())
+}
+
+func Test_ActionResultURL_ShouldReportAnAmbiguousRelation(t *testing.T) {
+	// given
+	action := Action{Links: []Link{
+		link("http://example.com/a", "flyte:actionResult"),
+		link("http://example.com/b", "http://example.com/swagger#!/action/actionResult"),
+	}}
+
+	// when
+	_, err := action.ResultURL()
+
+	// then
+	var linkErr *LinkError
+	require.True(t, errors.As(err, &linkErr))
+	assert.True(t, errors.Is(err, ErrAmbiguousLink))
+	assert.Equal(t, "actionResult", linkErr.Rel)
+}
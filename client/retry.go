@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Operation names a request the client makes to flyte-api. It is the endpoint label of the request metrics, and
+// selects the retry policy of the request.
+type Operation string
+
+const (
+	OperationAPILinks       Operation = "apiLinks"
+	OperationCreatePack     Operation = "createPack"
+	OperationPostEvent      Operation = "postEvent"
+	OperationTakeAction     Operation = "takeAction"
+	OperationCompleteAction Operation = "completeAction"
+)
+
+// The defaults for the retry policy settings that are not set.
+const (
+	defaultInitialBackoff = 200 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultMultiplier     = 2
+)
+
+// DefaultRetryableStatuses are the http statuses retried when a policy does not set RetryableStatuses.
+var DefaultRetryableStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy decides whether a failed request to flyte-api is sent again, and how long to wait first. The wait starts
+// at InitialBackoff and is multiplied by Multiplier for each retry, up to MaxBackoff, then randomised by Jitter.
+// A Retry-After header on the response is honoured: the request is not sent again sooner, and not at all if
+// Retry-After is longer than MaxBackoff.
+//
+// Requests that flyte-api may have processed are only retried if doing so is safe. GET requests and pack registration
+// are idempotent. Events and action results, sent with an Idempotency-Key header, are only idempotent if flyte-api
+// supports the header: it advertises a link whose rel ends with "idempotency", or WithIdempotencySupport is passed.
+// Other requests, such as TakeAction, are only retried when flyte-api cannot have processed them: the connection
+// could not be made, or flyte-api responded with 429 Too Many Requests or 503 Service Unavailable. A response lost
+// after the request was sent is never retried, as the action taken would be lost with it.
+type RetryPolicy struct {
+	MaxAttempts       int           // the attempts made, including the first. 0 or 1 means the request is not retried
+	InitialBackoff    time.Duration // the wait before the first retry. Defaults to 200ms
+	MaxBackoff        time.Duration // the longest wait between attempts. Defaults to 5 seconds
+	Multiplier        float64       // how much the wait grows with each retry. Defaults to 2
+	Jitter            float64       // the fraction of the wait that is randomised, between 0 and 1, e.g. 0.2 waits 80% to 120% of the backoff
+	RetryableStatuses []int         // the http statuses that are retried. Defaults to DefaultRetryableStatuses
+}
+
+// DefaultRetryPolicy makes 3 attempts, waiting 200ms then 400ms, give or take 20%.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, Jitter: 0.2}.withDefaults()
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultMultiplier
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	if p.RetryableStatuses == nil {
+		p.RetryableStatuses = append([]int(nil), DefaultRetryableStatuses...)
+	}
+	return p
+}
+
+// returns how long to wait before sending the request again, and false if it must not be retried. The attempt is
+// the number of the attempt that failed, starting at 1. idempotencyKeys is whether flyte-api supports the
+// Idempotency-Key header
+func (p RetryPolicy) retry(op Operation, attempt int, req *http.Request, resp *http.Response, err error, idempotencyKeys bool) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || req.Context().Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return 0, false
+	}
+	idempotent := isIdempotent(op, req, idempotencyKeys)
+	if err != nil {
+		// the request is only known not to have been sent when the connection could not be made
+		if !idempotent && !isDialError(err) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+	if !p.retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if !idempotent && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	wait := p.backoff(attempt)
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if retryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait, true
+}
+
+func (p RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the wait before the retry following the attempt, starting at 1, with jitter. Use it to back off
+// between attempts of something the client does not retry itself, e.g. registering a pack until it succeeds.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	return p.withDefaults().backoff(attempt)
+}
+
+// the wait before the retry following the attempt, with jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(p.MaxBackoff))
+	backoff *= 1 - p.Jitter + 2*p.Jitter*rand.Float64()
+	return time.Duration(backoff)
+}
+
+// whether the request can be sent more than once with the same effect. Registering a pack again updates it, and a
+// request with an Idempotency-Key header is only processed once if flyte-api supports the header
+func isIdempotent(op Operation, req *http.Request, idempotencyKeys bool) bool {
+	if op == OperationCreatePack {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return idempotencyKeys && req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// whether the error is a failure to connect, so the request was never sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parses a Retry-After header, either a number of seconds or an http date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,226 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var noWait = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Nanosecond, MaxBackoff: time.Second}
+
+func TestRetryPolicy_ShouldBackOffExponentiallyUpToMaxBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}.withDefaults()
+
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, p.backoff(3))
+}
+
+func TestRetryPolicy_ShouldRandomiseBackoffByJitter(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, Jitter: 0.2}.withDefaults()
+
+	for i := 0; i < 100; i++ {
+		backoff := p.backoff(1)
+		assert.True(t, backoff >= 800*time.Millisecond && backoff <= 1200*time.Millisecond, "got %v", backoff)
+	}
+}
+
+func TestRetryPolicy_ShouldOnlyRetryNonIdempotentRequestsTheServerRejected(t *testing.T) {
+	p := noWait.withDefaults()
+	req := httptest.NewRequest(http.MethodPost, "http://flyte/take", nil)
+	for status, retry := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusBadGateway:          false,
+		http.StatusGatewayTimeout:      false,
+		http.StatusInternalServerError: false,
+	} {
+		_, retried := p.retry(OperationTakeAction, 1, req, &http.Response{StatusCode: status, Header: http.Header{}}, nil, false)
+		assert.Equal(t, retry, retried, "status %d", status)
+	}
+}
+
+func TestRetryPolicy_ShouldRetryIdempotentRequestsOnRetryableStatuses(t *testing.T) {
+	p := noWait.withDefaults()
+	get := httptest.NewRequest(http.MethodGet, "http://flyte/v1", nil)
+	keyed := httptest.NewRequest(http.MethodPost, "http://flyte/events", nil)
+	keyed.Header.Set(IdempotencyKeyHeader, "key")
+	badGateway := &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}}
+
+	_, retried := p.retry(OperationAPILinks, 1, get, badGateway, nil, false)
+	assert.True(t, retried)
+	_, retried = p.retry(OperationPostEvent, 1, keyed, badGateway, nil, true)
+	assert.True(t, retried)
+	_, retried = p.retry(OperationAPILinks, 1, get, &http.Response{StatusCode: http.StatusInternalServerError}, nil, false)
+	assert.False(t, retried)
+	_, retried = p.retry(OperationAPILinks, 3, get, badGateway, nil, false)
+	assert.False(t, retried, "the last attempt must not be retried")
+}
+
+func TestRetryPolicy_ShouldOnlyRetryRequestsWithAnIdempotencyKeyOnRejection_WhenItIsNotSupported(t *testing.T) {
+	p := noWait.withDefaults()
+	keyed := httptest.NewRequest(http.MethodPost, "http://flyte/events", nil)
+	keyed.Header.Set(IdempotencyKeyHeader, "key")
+
+	_, retried := p.retry(OperationPostEvent, 1, keyed, &http.Response{StatusCode: http.StatusGatewayTimeout, Header: http.Header{}}, nil, false)
+	assert.False(t, retried, "flyte-api may have processed the request")
+	_, retried = p.retry(OperationPostEvent, 1, keyed, nil, errors.New("connection reset by peer"), false)
+	assert.False(t, retried, "flyte-api may have processed the request")
+	_, retried = p.retry(OperationPostEvent, 1, keyed, &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, nil, false)
+	assert.True(t, retried)
+	_, retried = p.retry(OperationPostEvent, 1, keyed, nil, errors.New("connection reset by peer"), true)
+	assert.True(t, retried)
+}
+
+func TestRetryPolicy_ShouldHonourRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}.withDefaults()
+	req := httptest.NewRequest(http.MethodPost, "http://flyte/events", nil)
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"2"}}}
+
+	wait, retried := p.retry(OperationPostEvent, 1, req, resp, nil, false)
+	assert.True(t, retried)
+	assert.Equal(t, 2*time.Second, wait)
+
+	resp.Header.Set("Retry-After", "10")
+	_, retried = p.retry(OperationPostEvent, 1, req, resp, nil, false)
+	assert.False(t, retried, "must not retry sooner than Retry-After")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	d, ok = parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func Test_PostEvent_ShouldRetryWithTheSameBody_WhenFlyteApiIsUnavailable(t *testing.T) {
+	// given flyte-api is unavailable for the first attempt
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	c.retries = newOptions([]Option{WithRetryPolicy(noWait)}).retries
+	c.eventsURL, _ = url.Parse(ts.URL + "/events")
+
+	// when
+	err := c.PostEvent(Event{Name: "Deployed"})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1])
+}
+
+func Test_TakeAction_ShouldNotRetry_WhenTheResponseIsLost(t *testing.T) {
+	// given flyte-api closes the connection after receiving the request
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	c.retries = newOptions([]Option{WithRetryPolicy(noWait)}).retries
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take")
+
+	// when
+	_, err := c.TakeAction()
+
+	// then the action that may have been taken is not taken again
+	require.Error(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func Test_TakeAction_ShouldRetry_WhenItCannotConnect(t *testing.T) {
+	// given nothing is listening
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	c := newTestClient("http://"+addr, t)
+	c.retries = newOptions([]Option{WithRetryPolicy(noWait)}).retries
+	m := metrics.NewPrometheus()
+	c.metrics = m
+	c.takeActionURL, _ = url.Parse("http://" + addr + "/take")
+
+	// when
+	_, err = c.TakeAction()
+
+	// then
+	require.Error(t, err)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `flyte_client_retries_total{endpoint="takeAction"} 2`)
+}
+
+func Test_Do_ShouldStopRetrying_WhenTheContextIsCancelled(t *testing.T) {
+	ts := mockServer(http.StatusServiceUnavailable, "")
+	defer ts.Close()
+
+	c := newTestClient(ts.URL, t)
+	c.retries = newOptions([]Option{WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour})}).retries
+	c.takeActionURL, _ = url.Parse(ts.URL + "/take")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.TakeActionContext(ctx)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+func TestWithRetryPolicy_ShouldSetThePolicyOfEachOperation(t *testing.T) {
+	o := newOptions([]Option{
+		WithRetryPolicy(DefaultRetryPolicy()),
+		WithRetryPolicy(RetryPolicy{}, OperationTakeAction),
+	})
+
+	assert.Equal(t, 3, o.retries[OperationPostEvent].MaxAttempts)
+	assert.Equal(t, 0, o.retries[OperationTakeAction].MaxAttempts)
+	assert.Empty(t, newOptions(nil).retries, "requests must not be retried by default")
+}
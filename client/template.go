@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the expansion of each URI template operator: the prefix of the expansion, the separator between variables, whether
+// variables are named, what named variables without a value expand to, and whether reserved characters are kept
+var templateOperators = map[byte]struct {
+	first, sep string
+	named      bool
+	ifEmpty    string
+	reserved   bool
+}{
+	0:   {"", ",", false, "", false},
+	'+': {"", ",", false, "", true},
+	'#': {"#", ",", false, "", true},
+	'.': {".", ".", false, "", false},
+	'/': {"/", "/", false, "", false},
+	';': {";", ";", true, "", false},
+	'?': {"?", "&", true, "=", false},
+	'&': {"&", "&", true, "=", false},
+}
+
+// expands a URI template (RFC 6570) up to level 3, with the prefix modifier of level 4. Variables that are not set
+// are left out of the expansion
+func expandTemplate(template string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed expression in uri template %q", template)
+		}
+		b.WriteString(template[:start])
+		expr := template[start+1 : start+end]
+		if err := expandExpression(&b, expr, vars); err != nil {
+			return "", err
+		}
+		template = template[start+end+1:]
+	}
+}
+
+func expandExpression(b *strings.Builder, expr string, vars map[string]string) error {
+	var op byte
+	if expr != "" && strings.IndexByte("+#./;?&", expr[0]) >= 0 {
+		op, expr = expr[0], expr[1:]
+	}
+	o := templateOperators[op]
+	first := true
+	for _, spec := range strings.Split(expr, ",") {
+		name, max, err := parseVarSpec(spec)
+		if err != nil {
+			return err
+		}
+		v, ok := vars[name]
+		if !ok {
+			continue
+		}
+		if max > 0 && len([]rune(v)) > max {
+			v = string([]rune(v)[:max])
+		}
+		if first {
+			b.WriteString(o.first)
+			first = false
+		} else {
+			b.WriteString(o.sep)
+		}
+		if o.named {
+			b.WriteString(name)
+			if v == "" {
+				b.WriteString(o.ifEmpty)
+				continue
+			}
+			b.WriteByte('=')
+		}
+		b.WriteString(encodeTemplateValue(v, o.reserved))
+	}
+	return nil
+}
+
+// parses a variable name, with an optional prefix length e.g. "name:3"
+func parseVarSpec(spec string) (string, int, error) {
+	if strings.HasSuffix(spec, "*") {
+		return "", 0, fmt.Errorf("uri template variable %q: exploded variables are not supported", spec)
+	}
+	name, max := spec, 0
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		n, err := strconv.Atoi(spec[i+1:])
+		if err != nil || n <= 0 || n >= 10000 {
+			return "", 0, fmt.Errorf("uri template variable %q has an invalid prefix length", spec)
+		}
+		name, max = spec[:i], n
+	}
+	if name == "" {
+		return "", 0, fmt.Errorf("uri template has an empty variable name")
+	}
+	return name, max, nil
+}
+
+// percent-encodes all but the unreserved characters, and the reserved characters if reserved is true
+func encodeTemplateValue(v string, reserved bool) string {
+	const unreserved = "-._~"
+	const reservedChars = ":/?#[]@!$&'()*+,;="
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte(unreserved, c) >= 0:
+			b.WriteByte(c)
+		case reserved && strings.IndexByte(reservedChars, c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
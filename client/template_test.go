@@ -0,0 +1,65 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ExpandTemplate_ShouldExpandEachOperator(t *testing.T) {
+	vars := map[string]string{"var": "value", "hello": "Hello World!", "path": "/foo/bar", "empty": "", "x": "1024", "y": "768"}
+	tests := map[string]string{
+		"{var}":             "value",
+		"{hello}":           "Hello%20World%21",
+		"{+path}/here":      "/foo/bar/here",
+		"X{#hello}":         "X#Hello%20World!",
+		"X{.var}":           "X.value",
+		"{/var,x}/here":     "/value/1024/here",
+		"{;x,y,empty}":      ";x=1024;y=768;empty",
+		"{?x,y,empty}":      "?x=1024&y=768&empty=",
+		"?fixed=yes{&x}":    "?fixed=yes&x=1024",
+		"{var:3}":           "val",
+		"{/undef}/{?undef}": "/",
+		"no expressions":    "no expressions",
+	}
+	for template, want := range tests {
+		// when
+		got, err := expandTemplate(template, vars)
+
+		// then
+		require.NoError(t, err, template)
+		assert.Equal(t, want, got, template)
+	}
+}
+
+func Test_ExpandTemplate_ShouldRejectInvalidTemplates(t *testing.T) {
+	tests := map[string]string{
+		"/packs/{packName":  `unclosed expression in uri template "/packs/{packName"`,
+		"/packs/{list*}":    `uri template variable "list*": exploded variables are not supported`,
+		"/packs/{name:abc}": `uri template variable "name:abc" has an invalid prefix length`,
+		"/packs/{}":         "uri template has an empty variable name",
+	}
+	for template, want := range tests {
+		// when
+		_, err := expandTemplate(template, nil)
+
+		// then
+		assert.EqualError(t, err, want, template)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The defaults of http.DefaultTransport, used for Transport settings that are not set.
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// Transport tunes the connections to the flyte api. Zero values use the defaults of http.DefaultTransport.
+type Transport struct {
+	DialTimeout         time.Duration // how long to wait for a connection. Defaults to 30 seconds
+	KeepAlive           time.Duration // the interval of TCP keep-alive probes. Defaults to 30 seconds
+	MaxIdleConnsPerHost int           // the idle connections kept open to flyte-api. Defaults to 2
+	TLSHandshakeTimeout time.Duration // defaults to 10 seconds
+	DisableHTTP2        bool          // use HTTP/1.1 only. HTTP/2 is used by default when flyte-api supports it
+}
+
+// creates the http transport with the TLS configuration and proxy passed in
+func (t Transport) httpTransport(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   orDefault(t.DialTimeout, defaultDialTimeout),
+		KeepAlive: orDefault(t.KeepAlive, defaultKeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !t.DisableHTTP2,
+		MaxIdleConns:          defaultMaxIdleConns,
+		MaxIdleConnsPerHost:   t.MaxIdleConnsPerHost,
+		IdleConnTimeout:       defaultIdleConnTimeout,
+		TLSHandshakeTimeout:   orDefault(t.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if t.DisableHTTP2 {
+		// a non-nil empty map stops the transport upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// returns a proxy function that sends requests through proxyURL, except to the hosts in noProxy. No proxy is used
+// if proxyURL is nil
+func proxyFunc(proxyURL *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	if proxyURL == nil {
+		return nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// reports whether the url matches an entry of the no-proxy list. Entries are "*" for every host, an IP address or
+// CIDR range, or a host name that also matches its subdomains, e.g. "example.com" or ".example.com". Entries with
+// a port only match that port
+func bypassProxy(u *url.URL, noProxy []string) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimPrefix(entryHost, ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
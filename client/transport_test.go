@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_NewHttpClient_ShouldUseTransportDefaults(t *testing.T) {
+	httpClient := newHttpClient(10*time.Second, false)
+
+	transport := httpClient.Transport.(*http.Transport)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.Nil(t, transport.TLSNextProto)
+	assert.Equal(t, defaultTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.NotNil(t, transport.Proxy, "the proxy environment variables must be used by default")
+}
+
+func Test_NewHttpClient_ShouldUseTransportOption(t *testing.T) {
+	httpClient := newHttpClient(10*time.Second, false, WithTransport(Transport{
+		MaxIdleConnsPerHost: 16,
+		TLSHandshakeTimeout: 3 * time.Second,
+		DisableHTTP2:        true,
+	}))
+
+	transport := httpClient.Transport.(*http.Transport)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.Empty(t, transport.TLSNextProto)
+}
+
+func Test_NewClient_ShouldConnectThroughProxyWithCredentials(t *testing.T) {
+	// given a proxy that answers for flyte-api
+	var proxied []*http.Request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(flyteApiLinksResponse))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	// when we create a client for a host that is only reachable through the proxy
+	baseUrl, _ := url.Parse("http://flyte.example.com:8080")
+	NewClient(baseUrl, 10*time.Second, WithProxy(proxyURL))
+
+	// then the request is sent to the proxy with the credentials
+	require.NotEmpty(t, proxied)
+	assert.Equal(t, "http://flyte.example.com:8080/v1", proxied[0].RequestURI)
+	credentials := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	assert.Equal(t, "Basic "+credentials, proxied[0].Header.Get("Proxy-Authorization"))
+}
+
+func Test_WithProxy_ShouldConnectDirectlyToHostsInNoProxy(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy.example.com:3128")
+	o := newOptions([]Option{WithProxy(proxyURL, "localhost", ".internal", "10.0.0.0/8")})
+
+	for rawURL, want := range map[string]*url.URL{
+		"http://localhost:8080/v1":       nil,
+		"https://flyte.internal/v1":      nil,
+		"https://10.1.2.3/v1":            nil,
+		"https://flyte.example.com/v1":   proxyURL,
+		"https://notinternal.example/v1": proxyURL,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		got, err := o.proxy(req)
+
+		require.NoError(t, err)
+		assert.Equal(t, want, got, rawURL)
+	}
+}
+
+func Test_WithProxy_ShouldConnectDirectly_WhenProxyIsNil(t *testing.T) {
+	o := newOptions([]Option{WithProxy(nil)})
+
+	assert.Nil(t, o.proxy)
+}
+
+func Test_BypassProxy(t *testing.T) {
+	cases := []struct {
+		url     string
+		noProxy []string
+		bypass  bool
+	}{
+		{"http://flyte.example.com", []string{"*"}, true},
+		{"http://flyte.example.com", []string{"example.com"}, true},
+		{"http://flyte.example.com", []string{".example.com"}, true},
+		{"http://FLYTE.example.com", []string{"flyte.example.com"}, true},
+		{"http://flyte.example.com", []string{"ample.com"}, false},
+		{"http://flyte.example.com:8080", []string{"flyte.example.com:8080"}, true},
+		{"http://flyte.example.com:8080", []string{"flyte.example.com:9090"}, false},
+		{"https://flyte.example.com", []string{"flyte.example.com:443"}, true},
+		{"http://192.168.1.10:8080", []string{"192.168.1.10"}, true},
+		{"http://192.168.1.10", []string{"192.168.0.0/16"}, true},
+		{"http://192.169.1.10", []string{"192.168.0.0/16"}, false},
+		{"http://[::1]:8080", []string{"::1"}, true},
+		{"http://flyte.example.com", nil, false},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		assert.Equal(t, c.bypass, bypassProxy(u, c.noProxy), "%s %v", c.url, c.noProxy)
+	}
+}
@@ -17,97 +17,421 @@ limitations under the License.
 package config
 
 import (
-	"github.com/rs/zerolog/log"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/redact"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	apiTimeoutOutDefault   = time.Second * 10
-	flyteApiEnvName        = "FLYTE_API"
-	FlyteJWTEnvName        = "FLYTE_JWT"
-	flyteLabelsEnvName     = "FLYTE_LABELS"
-	flyteApiTimeOutEnvName = "FLYTE_API_TIMEOUT"
+	apiTimeoutOutDefault       = time.Second * 10
+	pollIntervalDefault        = time.Second * 5
+	healthAddrDefault          = ":8090"
+	circuitFailuresDefault     = 5
+	circuitOpenDefault         = 30 * time.Second
+	retryAttemptsDefault       = 3
+	retryInitialDefault        = 200 * time.Millisecond
+	retryMaxBackoffDefault     = 5 * time.Second
+	maxRetryAttemptsLimit      = 10
+	maxConcurrencyLimit        = 10000
+	maxPayloadSizeLimit        = 1 << 20
+	maxHalfOpenProbesLimit     = 100
+	maxBodySizeLimit           = 1 << 30
+	failoverThresholdDefault   = 3
+	failbackIntervalDefault    = time.Minute
+	linkTTLDefault             = 10 * time.Minute
+	flyteApiEnvName            = "FLYTE_API"
+	FlyteJWTEnvName            = "FLYTE_JWT"
+	flyteLabelsEnvName         = "FLYTE_LABELS"
+	flyteApiTimeOutEnvName     = "FLYTE_API_TIMEOUT"
+	FlyteConfigEnvName         = "FLYTE_CONFIG"
+	flyteInsecureEnvName       = "FLYTE_INSECURE"
+	flytePollIntervalEnvName   = "FLYTE_POLL_INTERVAL"
+	flyteMaxConcurrencyEnvName = "FLYTE_MAX_CONCURRENCY"
+	flyteHealthAddrEnvName     = "FLYTE_HEALTH_ADDR"
+	flyteHealthTLSCertEnvName  = "FLYTE_HEALTH_TLS_CERT"
+	flyteHealthTLSKeyEnvName   = "FLYTE_HEALTH_TLS_KEY"
+	flyteRedactFieldsEnvName   = "FLYTE_REDACT_FIELDS"
+	flyteProxyEnvName          = "FLYTE_PROXY"
+	flyteNoProxyEnvName        = "FLYTE_NO_PROXY"
+	flyteDialTimeoutEnvName    = "FLYTE_DIAL_TIMEOUT"
+	flyteKeepAliveEnvName      = "FLYTE_KEEP_ALIVE"
+	flyteMaxIdlePerHostEnvName = "FLYTE_MAX_IDLE_CONNS_PER_HOST"
+	flyteTLSHandshakeEnvName   = "FLYTE_TLS_HANDSHAKE_TIMEOUT"
+	flyteHTTP2EnvName          = "FLYTE_HTTP2"
+	flyteCBFailuresEnvName     = "FLYTE_CIRCUIT_FAILURE_THRESHOLD"
+	flyteCBOpenEnvName         = "FLYTE_CIRCUIT_OPEN_INTERVAL"
+	flyteCBProbesEnvName       = "FLYTE_CIRCUIT_HALF_OPEN_PROBES"
+	flyteRetryAttemptsEnvName  = "FLYTE_RETRY_MAX_ATTEMPTS"
+	flyteRetryInitialEnvName   = "FLYTE_RETRY_INITIAL_BACKOFF"
+	flyteRetryMaxEnvName       = "FLYTE_RETRY_MAX_BACKOFF"
+	flyteEventEncodingEnvName  = "FLYTE_EVENT_ENCODING"
+	flyteCompressionEnvName    = "FLYTE_COMPRESSION_THRESHOLD"
+	flyteMaxResponseEnvName    = "FLYTE_MAX_RESPONSE_SIZE"
+	flyteFailoverEnvName       = "FLYTE_API_FAILOVER"
+	flyteFailoverThreshEnvName = "FLYTE_FAILOVER_THRESHOLD"
+	flyteFailbackEnvName       = "FLYTE_FAILBACK_INTERVAL"
+	flyteLinkTTLEnvName        = "FLYTE_LINK_TTL"
+	flyteRedactMaxSizeEnvName  = "FLYTE_REDACT_MAX_PAYLOAD_SIZE"
+	redactedValue              = "****"
 )
 
 var GetEnv = os.Getenv
 
 type Values struct {
-	Labels      map[string]string
-	FlyteApiUrl *url.URL
-	Timeout     time.Duration
+	Labels         map[string]string
+	FlyteApiUrl    *url.URL
+	Timeout        time.Duration
+	JWT            string         // sent as a bearer token with every request to the flyte api
+	Insecure       bool           // skips verification of the flyte api server certificate
+	TLS            TLSFiles       // optional certificate files used when connecting to the flyte api
+	PollInterval   time.Duration  // how often to poll for actions when none are available
+	MaxConcurrency int            // the maximum number of actions handled at the same time, 0 means unlimited
+	HealthAddr     string         // the address the health check server listens on, in the form 'host:port'
+	HealthTLS      TLSFiles       // optional certificate and key files used to serve the health checks over https. CAFile is not used
+	Redaction      redact.Policy  // how events and actions are described in logs and errors. Configured fields are added to redact.DefaultFields
+	Proxy          Proxy          // optional proxy used to connect to the flyte api, instead of HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	Transport      Transport      // tunes the connections to the flyte api
+	CircuitBreaker CircuitBreaker // when requests to the flyte api fail fast rather than waiting for the timeout
+	Retry          Retry          // how failed requests to the flyte api are retried, when it is safe to do so
+	EventEncoding  string         // how events are sent: flyte (the default), cloudevents-structured, cloudevents-binary or negotiate
+	Compression    int            // request bodies of at least this many bytes are gzipped when flyte-api accepts it, 0 disables compression
+	MaxResponse    int            // the largest flyte api response body read, in bytes, 0 means no limit
+	Failover       Failover       // the secondary flyte api endpoints used while FlyteApiUrl is failing
+	LinkTTL        time.Duration  // how long the flyte api links are used before they are discovered again, 0 means until they are not found
 }
 
-// returns the environment values
-func FromEnvironment() Values {
-	return Values{FlyteApiUrl: getFlyteApiUrl(), Labels: getLabels(), Timeout: getApiTimeOut()}
+// Failover switches to the first of URLs that is available after FailureThreshold consecutive failed requests to the
+// flyte api in use, and back to FlyteApiUrl once it is healthy, which is checked every FailbackInterval.
+type Failover struct {
+	URLs             []*url.URL    // the secondary endpoints, in order of preference. None means failover is disabled
+	FailureThreshold int           // defaults to 3
+	FailbackInterval time.Duration // defaults to 1 minute
 }
 
-// checks that the flyteApi env FLYTE_API is set
-func getFlyteApiUrl() *url.URL {
-	apiEnvUrl := GetEnv(flyteApiEnvName)
-	if apiEnvUrl == "" {
-		log.Fatal().Msgf("%s environment variable is not set", flyteApiEnvName)
-	}
+// the event encodings, as named by client.EventEncoding
+var eventEncodings = []string{"flyte", "cloudevents-structured", "cloudevents-binary", "negotiate"}
 
-	flyteApiUrl, err := url.Parse(apiEnvUrl)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("%s environment variable is not set to a valid URL", flyteApiEnvName)
+// Retry makes up to MaxAttempts attempts of a request to the flyte api, waiting InitialBackoff before the first retry
+// and doubling the wait for each retry, up to MaxBackoff.
+type Retry struct {
+	MaxAttempts    int           // defaults to 3, 1 means requests are not retried
+	InitialBackoff time.Duration // defaults to 200ms
+	MaxBackoff     time.Duration // defaults to 5 seconds
+}
+
+// CircuitBreaker opens after FailureThreshold consecutive failed requests to the flyte api, failing requests fast for
+// OpenInterval, then closes once HalfOpenProbes requests succeed.
+type CircuitBreaker struct {
+	FailureThreshold int           // defaults to 5, 0 disables the circuit breaker
+	OpenInterval     time.Duration // defaults to 30 seconds
+	HalfOpenProbes   int           // defaults to 1
+}
+
+// Proxy is the proxy used to connect to the flyte api.
+type Proxy struct {
+	URL     *url.URL // the http, https or socks5 proxy, with optional credentials. Not set means the proxy environment variables are used
+	NoProxy []string // hosts connected to directly: "*", IP addresses, CIDR ranges or host names, which match their subdomains
+}
+
+// Transport tunes the connections to the flyte api. Zero durations use the client defaults.
+type Transport struct {
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	MaxIdleConnsPerHost int // 0 uses the client default
+	TLSHandshakeTimeout time.Duration
+	HTTP2               bool // whether HTTP/2 is used when flyte-api supports it. Defaults to true
+}
+
+// The PEM encoded files used to connect to the flyte api over TLS. All are optional.
+type TLSFiles struct {
+	CAFile   string // CA certificates used to verify the flyte api server certificate, instead of the system ones
+	CertFile string // client certificate, requires KeyFile
+	KeyFile  string // client certificate private key, requires CertFile
+}
+
+// LookupEnv returns the value of the named setting and whether it is set. An empty value is treated as not set.
+type LookupEnv func(name string) (string, bool)
+
+// Option customises how Load reads the configuration.
+type Option func(*options)
+
+type options struct {
+	lookup LookupEnv
+}
+
+// WithLookupEnv reads the settings using the lookup function passed in instead of the process environment.
+func WithLookupEnv(lookup LookupEnv) Option {
+	return func(o *options) {
+		o.lookup = lookup
 	}
+}
 
-	return flyteApiUrl
+// WithEnv reads the settings from the map passed in instead of the process environment.
+func WithEnv(env map[string]string) Option {
+	return WithLookupEnv(func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	})
+}
+
+// Errors holds every problem found while loading the configuration.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(msgs, "; "))
 }
 
-// checks that FLYTE_LABELS is set and it's value(s) are correct
-func getLabels() (labels map[string]string) {
-	labelsString := GetEnv(flyteLabelsEnvName)
-	labels = make(map[string]string)
+// Load reads and validates the configuration. By default the settings are read from the environment.
+// If FLYTE_CONFIG names a config file, the settings are read from the file first and the environment variables
+// override them. All validation problems are gathered and returned together as Errors.
+func Load(opts ...Option) (Values, error) {
+	o := options{lookup: func(name string) (string, bool) {
+		v := GetEnv(name)
+		return v, v != ""
+	}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	e := &env{lookup: o.lookup}
 
-	if labelsString == "" {
-		log.Info().Msgf("%s environment variable is not set", flyteLabelsEnvName)
-		return labels
+	values := Values{
+		Labels:       map[string]string{},
+		Timeout:      apiTimeoutOutDefault,
+		PollInterval: pollIntervalDefault,
+		HealthAddr:   healthAddrDefault,
+		Redaction:    redact.Default(),
+		Transport:    Transport{HTTP2: true},
+		CircuitBreaker: CircuitBreaker{
+			FailureThreshold: circuitFailuresDefault,
+			OpenInterval:     circuitOpenDefault,
+			HalfOpenProbes:   1,
+		},
+		Retry: Retry{
+			MaxAttempts:    retryAttemptsDefault,
+			InitialBackoff: retryInitialDefault,
+			MaxBackoff:     retryMaxBackoffDefault,
+		},
+		EventEncoding: eventEncodings[0],
+		LinkTTL:       linkTTLDefault,
+		Failover: Failover{
+			FailureThreshold: failoverThresholdDefault,
+			FailbackInterval: failbackIntervalDefault,
+		},
+	}
+	var configFile string
+	e.stringVar(FlyteConfigEnvName, &configFile)
+	if configFile != "" {
+		// a file that cannot be read is reported with the problems of the environment variables
+		if file, err := readFile(configFile); err != nil {
+			e.errs = append(e.errs, err)
+		} else {
+			e.errs = append(e.errs, file.apply(&values)...)
+		}
 	}
 
-	// labels format: 'key=value,key=value'
-	for _, label := range strings.Split(labelsString, ",") {
-		items := strings.SplitN(label, "=", 2)
-		if len(items) != 2 {
-			log.Fatal().Msgf("invalid format of %s environment variable: %v", flyteLabelsEnvName, labelsString)
+	e.urlVar(flyteApiEnvName, &values.FlyteApiUrl)
+	if _, set := e.get(flyteApiEnvName); !set && values.FlyteApiUrl == nil {
+		e.errorf("%s environment variable is not set", flyteApiEnvName)
+	}
+	e.labelsVar(flyteLabelsEnvName, &values.Labels)
+	e.durationVar(flyteApiTimeOutEnvName, &values.Timeout)
+	e.stringVar(FlyteJWTEnvName, &values.JWT)
+	e.boolVar(flyteInsecureEnvName, &values.Insecure)
+	e.durationVar(flytePollIntervalEnvName, &values.PollInterval)
+	e.intVar(flyteMaxConcurrencyEnvName, 0, maxConcurrencyLimit, &values.MaxConcurrency)
+	e.addrVar(flyteHealthAddrEnvName, &values.HealthAddr)
+	e.stringVar(flyteHealthTLSCertEnvName, &values.HealthTLS.CertFile)
+	e.stringVar(flyteHealthTLSKeyEnvName, &values.HealthTLS.KeyFile)
+	e.appendListVar(flyteRedactFieldsEnvName, &values.Redaction.Fields)
+	e.intVar(flyteRedactMaxSizeEnvName, 0, maxPayloadSizeLimit, &values.Redaction.MaxSize)
+	e.urlVar(flyteProxyEnvName, &values.Proxy.URL)
+	e.listVar(flyteNoProxyEnvName, &values.Proxy.NoProxy)
+	e.durationVar(flyteDialTimeoutEnvName, &values.Transport.DialTimeout)
+	e.durationVar(flyteKeepAliveEnvName, &values.Transport.KeepAlive)
+	e.intVar(flyteMaxIdlePerHostEnvName, 0, maxConcurrencyLimit, &values.Transport.MaxIdleConnsPerHost)
+	e.durationVar(flyteTLSHandshakeEnvName, &values.Transport.TLSHandshakeTimeout)
+	e.boolVar(flyteHTTP2EnvName, &values.Transport.HTTP2)
+	e.intVar(flyteCBFailuresEnvName, 0, maxConcurrencyLimit, &values.CircuitBreaker.FailureThreshold)
+	e.durationVar(flyteCBOpenEnvName, &values.CircuitBreaker.OpenInterval)
+	e.intVar(flyteCBProbesEnvName, 1, maxHalfOpenProbesLimit, &values.CircuitBreaker.HalfOpenProbes)
+	e.intVar(flyteRetryAttemptsEnvName, 1, maxRetryAttemptsLimit, &values.Retry.MaxAttempts)
+	e.durationVar(flyteRetryInitialEnvName, &values.Retry.InitialBackoff)
+	e.durationVar(flyteRetryMaxEnvName, &values.Retry.MaxBackoff)
+	e.stringVar(flyteEventEncodingEnvName, &values.EventEncoding)
+	e.intVar(flyteCompressionEnvName, 0, maxBodySizeLimit, &values.Compression)
+	e.intVar(flyteMaxResponseEnvName, 0, maxBodySizeLimit, &values.MaxResponse)
+	e.urlListVar(flyteFailoverEnvName, &values.Failover.URLs)
+	e.intVar(flyteFailoverThreshEnvName, 1, maxConcurrencyLimit, &values.Failover.FailureThreshold)
+	e.durationVar(flyteFailbackEnvName, &values.Failover.FailbackInterval)
+	e.durationVar(flyteLinkTTLEnvName, &values.LinkTTL)
+
+	errs := e.errs
+	if err := validateProxy(values.Proxy.URL); err != nil {
+		errs = append(errs, err)
+	}
+	for _, u := range values.Failover.URLs {
+		if u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("failover endpoint %s must be an absolute url with a host", redact.URL(u)))
 		}
-		labels[strings.TrimSpace(items[0])] = strings.TrimSpace(items[1])
 	}
-	return labels
+	if err := validateEventEncoding(values.EventEncoding); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := values.TLSConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if values.HealthTLS.CertFile != "" || values.HealthTLS.KeyFile != "" {
+		if _, err := tls.LoadX509KeyPair(values.HealthTLS.CertFile, values.HealthTLS.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("cannot load healthcheck server certificate: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return Values{}, errs
+	}
+	return values, nil
 }
 
-// checks that the FLYTE_API_TIMEOUT is set, and if not sets to the default value.
-func getApiTimeOut() time.Duration {
+// TLSConfig creates the TLS configuration for connecting to the flyte api from the TLS files and the Insecure flag.
+// If neither are set, nil is returned and the defaults are used.
+func (v Values) TLSConfig() (*tls.Config, error) {
+	if v.TLS == (TLSFiles{}) && !v.Insecure {
+		return nil, nil
+	}
 
-	apiTimeOut := GetEnv(flyteApiTimeOutEnvName)
+	cfg := &tls.Config{InsecureSkipVerify: v.Insecure}
+	if v.TLS.CAFile != "" {
+		pem, err := ioutil.ReadFile(v.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", v.TLS.CAFile)
+		}
+	}
+	if v.TLS.CertFile != "" || v.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(v.TLS.CertFile, v.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
 
-	if apiTimeOut == "" {
-		log.Info().Msgf("FLYTE_API_TIMEOUT environment variable is not set, setting to default of %v", apiTimeoutOutDefault)
-		return apiTimeoutOutDefault
+// Redacted describes the configuration for startup logging, with secrets such as the JWT and URL passwords masked.
+func (v Values) Redacted() string {
+	settings := map[string]string{
+		"api.url":                             redact.URL(v.FlyteApiUrl),
+		"api.timeout":                         v.Timeout.String(),
+		"api.insecure":                        strconv.FormatBool(v.Insecure),
+		"api.tls.caFile":                      v.TLS.CAFile,
+		"api.tls.certFile":                    v.TLS.CertFile,
+		"api.tls.keyFile":                     v.TLS.KeyFile,
+		"pack.pollInterval":                   v.PollInterval.String(),
+		"pack.maxConcurrency":                 strconv.Itoa(v.MaxConcurrency),
+		"pack.labels":                         fmt.Sprintf("%v", v.Labels),
+		"health.addr":                         v.HealthAddr,
+		"health.tls.certFile":                 v.HealthTLS.CertFile,
+		"health.tls.keyFile":                  v.HealthTLS.KeyFile,
+		"redact.fields":                       strconv.Itoa(len(v.Redaction.Fields)), // the number of fields, the names may resemble the secrets
+		"redact.maxPayloadSize":               strconv.Itoa(v.Redaction.MaxSize),
+		"api.proxy.url":                       redact.URL(v.Proxy.URL),
+		"api.proxy.noProxy":                   strings.Join(v.Proxy.NoProxy, ","),
+		"api.transport.dialTimeout":           v.Transport.DialTimeout.String(),
+		"api.transport.keepAlive":             v.Transport.KeepAlive.String(),
+		"api.transport.maxIdleConnsPerHost":   strconv.Itoa(v.Transport.MaxIdleConnsPerHost),
+		"api.transport.tlsHandshakeTimeout":   v.Transport.TLSHandshakeTimeout.String(),
+		"api.transport.http2":                 strconv.FormatBool(v.Transport.HTTP2),
+		"api.circuitBreaker.failureThreshold": strconv.Itoa(v.CircuitBreaker.FailureThreshold),
+		"api.circuitBreaker.openInterval":     v.CircuitBreaker.OpenInterval.String(),
+		"api.circuitBreaker.halfOpenProbes":   strconv.Itoa(v.CircuitBreaker.HalfOpenProbes),
+		"api.retry.maxAttempts":               strconv.Itoa(v.Retry.MaxAttempts),
+		"api.retry.initialBackoff":            v.Retry.InitialBackoff.String(),
+		"api.retry.maxBackoff":                v.Retry.MaxBackoff.String(),
+		"api.eventEncoding":                   v.EventEncoding,
+		"api.compressionThreshold":            strconv.Itoa(v.Compression),
+		"api.maxResponseSize":                 strconv.Itoa(v.MaxResponse),
+		"api.failover.urls":                   redactURLs(v.Failover.URLs),
+		"api.failover.failureThreshold":       strconv.Itoa(v.Failover.FailureThreshold),
+		"api.failover.failbackInterval":       v.Failover.FailbackInterval.String(),
+		"api.linkTTL":                         v.LinkTTL.String(),
+	}
+	if v.JWT != "" {
+		settings["api.jwt"] = redactedValue
 	}
 
-	apiTimeOutInt, err := strconv.Atoi(apiTimeOut)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("%s is an invalid integer value", flyteApiTimeOutEnvName)
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dump := make([]string, len(keys))
+	for i, k := range keys {
+		dump[i] = fmt.Sprintf("%s=%s", k, settings[k])
 	}
+	return strings.Join(dump, " ")
+}
 
-	if apiTimeOutInt < 0 {
-		log.Fatal().Msgf("%s has been set to an invalid value: %v", flyteApiTimeOutEnvName, apiTimeOutInt)
+func validateEventEncoding(encoding string) error {
+	for _, e := range eventEncodings {
+		if encoding == e {
+			return nil
+		}
+	}
+	return fmt.Errorf("event encoding %q must be one of %s", encoding, strings.Join(eventEncodings, ", "))
+}
+
+// the proxy must be an absolute http, https or socks5 url
+func validateProxy(u *url.URL) error {
+	if u == nil {
+		return nil
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		if u.Host != "" {
+			return nil
+		}
 	}
+	return fmt.Errorf("proxy %s must be an http, https or socks5 url with a host", redact.URL(u))
+}
 
-	return time.Second * time.Duration(apiTimeOutInt)
+// returns the environment values. Invalid values are fatal - use Load to handle them yourself.
+func FromEnvironment() Values {
+	values, err := Load()
+	if err != nil {
+		logging.Fatal(logging.Default(), "cannot load flyte configuration", logging.KeyError, err)
+	}
+	return values
 }
 
+// GetJWT returns the FLYTE_JWT environment variable. It is read from the environment even when the rest of the
+// configuration is loaded from another source - use the Values JWT returned by Load instead.
 func GetJWT() string {
 	jwt := GetEnv(FlyteJWTEnvName)
 	if jwt != "" {
-		log.Info().Msgf("%s environment variable is set.", FlyteJWTEnvName)
+		logging.Default().Info(FlyteJWTEnvName + " environment variable is set.")
 	}
 	return jwt
 }
+
+func redactURLs(urls []*url.URL) string {
+	redacted := make([]string, len(urls))
+	for i, u := range urls {
+		redacted[i] = redact.URL(u)
+	}
+	return strings.Join(redacted, ",")
+}
@@ -18,6 +18,7 @@ package config
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/url"
 	"testing"
 	"time"
@@ -83,3 +84,57 @@ func TestShouldNotGetJWTFromEnvironment(t *testing.T) {
 
 	assert.Equal(t, "", GetJWT())
 }
+
+func TestLoad_ShouldReadSettingsFromInjectedEnvironment(t *testing.T) {
+	cfg, err := Load(WithEnv(map[string]string{
+		flyteApiEnvName:        "http://localhost:8080",
+		flyteApiTimeOutEnvName: "3",
+		flyteLabelsEnvName:     "env=prod",
+	}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", cfg.FlyteApiUrl.String())
+	assert.Equal(t, 3*time.Second, cfg.Timeout)
+	assert.Equal(t, map[string]string{"env": "prod"}, cfg.Labels)
+}
+
+func TestLoad_ShouldReadSettingsUsingLookupFunction(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == flyteApiEnvName {
+			return "http://localhost:8080", true
+		}
+		return "", false
+	}
+
+	cfg, err := Load(WithLookupEnv(lookup))
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", cfg.FlyteApiUrl.String())
+	assert.Equal(t, apiTimeoutOutDefault, cfg.Timeout)
+	assert.Equal(t, map[string]string{}, cfg.Labels)
+}
+
+func TestLoad_ShouldReturnAllValidationProblems(t *testing.T) {
+	_, err := Load(WithEnv(map[string]string{
+		flyteApiTimeOutEnvName: "-1",
+		flyteLabelsEnvName:     "env",
+	}))
+
+	require.Error(t, err)
+	require.IsType(t, Errors{}, err)
+	assert.Len(t, err.(Errors), 3)
+	assert.Equal(t, "invalid configuration: FLYTE_API environment variable is not set; "+
+		"invalid format of FLYTE_LABELS environment variable: env; "+
+		"FLYTE_API_TIMEOUT has been set to an invalid value: -1", err.Error())
+}
+
+func TestLoad_ShouldReturnErrorForInvalidValues(t *testing.T) {
+	_, err := Load(WithEnv(map[string]string{
+		flyteApiEnvName:        "://not a url",
+		flyteApiTimeOutEnvName: "ten",
+	}))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FLYTE_API environment variable is not set to a valid URL")
+	assert.Contains(t, err.Error(), "FLYTE_API_TIMEOUT is an invalid duration")
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// env reads typed settings using the lookup function. A setting that is not set leaves the destination unchanged,
+// so defaults and config file values are kept. Every invalid value is collected in errs rather than returned,
+// so all problems can be reported at once.
+type env struct {
+	lookup LookupEnv
+	errs   Errors
+}
+
+func (e *env) get(name string) (string, bool) {
+	v, ok := e.lookup(name)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+func (e *env) errorf(format string, args ...interface{}) {
+	e.errs = append(e.errs, fmt.Errorf(format, args...))
+}
+
+func (e *env) stringVar(name string, dst *string) {
+	if v, ok := e.get(name); ok {
+		*dst = v
+	}
+}
+
+// accepts Go duration syntax (e.g. "500ms", "2m") or, for backwards compatibility, a bare integer number of seconds.
+// Negative durations are invalid
+func (e *env) durationVar(name string, dst *time.Duration) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	d, err := parseDuration(v)
+	if err != nil {
+		e.errorf("%s is an invalid duration: %v", name, err)
+		return
+	}
+	if d < 0 {
+		e.errorf("%s has been set to an invalid value: %v", name, v)
+		return
+	}
+	*dst = d
+}
+
+func (e *env) boolVar(name string, dst *bool) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errorf("%s is an invalid boolean value: %q", name, v)
+		return
+	}
+	*dst = b
+}
+
+// parses an integer that must be between min and max inclusive
+func (e *env) intVar(name string, min, max int, dst *int) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		e.errorf("%s is an invalid integer value: %q", name, v)
+		return
+	}
+	if i < min || i > max {
+		e.errorf("%s must be between %d and %d, but was %d", name, min, max, i)
+		return
+	}
+	*dst = i
+}
+
+func (e *env) urlVar(name string, dst **url.URL) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		e.errorf("%s environment variable is not set to a valid URL: %v", name, err)
+		return
+	}
+	*dst = u
+}
+
+// parses a comma separated list of URLs
+func (e *env) urlListVar(name string, dst *[]*url.URL) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	var urls []*url.URL
+	for _, s := range strings.Split(v, ",") {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil || strings.TrimSpace(s) == "" {
+			e.errorf("%s environment variable contains an invalid URL: %q", name, s)
+			return
+		}
+		urls = append(urls, u)
+	}
+	*dst = urls
+}
+
+// parses a comma separated list
+func (e *env) listVar(name string, dst *[]string) {
+	if _, ok := e.get(name); !ok {
+		return
+	}
+	*dst = nil
+	e.appendListVar(name, dst)
+}
+
+// parses a comma separated list, appending the items to dst
+func (e *env) appendListVar(name string, dst *[]string) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
+
+// parses a network address in the form 'host:port', where the host is optional
+func (e *env) addrVar(name string, dst *string) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	if err := validateAddr(v); err != nil {
+		e.errorf("%s is an invalid address: %v", name, err)
+		return
+	}
+	*dst = v
+}
+
+// parses labels in the format 'key=value,key=value'
+func (e *env) labelsVar(name string, dst *map[string]string) {
+	v, ok := e.get(name)
+	if !ok {
+		return
+	}
+	labels := make(map[string]string)
+	for _, label := range strings.Split(v, ",") {
+		items := strings.SplitN(label, "=", 2)
+		if len(items) != 2 {
+			e.errorf("invalid format of %s environment variable: %v", name, v)
+			return
+		}
+		labels[strings.TrimSpace(items[0])] = strings.TrimSpace(items[1])
+	}
+	*dst = labels
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
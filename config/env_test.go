@@ -0,0 +1,167 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestEnv(values map[string]string) *env {
+	return &env{lookup: func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}}
+}
+
+func TestEnvDuration_ShouldAcceptGoDurationsAndBareSeconds(t *testing.T) {
+	e := newTestEnv(map[string]string{"MS": "500ms", "MIN": "2m", "SECONDS": "30", "ZERO": "0"})
+
+	var ms, min, seconds, zero time.Duration
+	e.durationVar("MS", &ms)
+	e.durationVar("MIN", &min)
+	e.durationVar("SECONDS", &seconds)
+	e.durationVar("ZERO", &zero)
+
+	require.Empty(t, e.errs)
+	assert.Equal(t, 500*time.Millisecond, ms)
+	assert.Equal(t, 2*time.Minute, min)
+	assert.Equal(t, 30*time.Second, seconds)
+	assert.Equal(t, time.Duration(0), zero)
+}
+
+func TestEnvDuration_ShouldRejectInvalidAndNegativeDurations(t *testing.T) {
+	e := newTestEnv(map[string]string{"INVALID": "soon", "NEGATIVE": "-5s"})
+
+	d := time.Second
+	e.durationVar("INVALID", &d)
+	e.durationVar("NEGATIVE", &d)
+
+	require.Len(t, e.errs, 2)
+	assert.Contains(t, e.errs[0].Error(), "INVALID is an invalid duration")
+	assert.EqualError(t, e.errs[1], "NEGATIVE has been set to an invalid value: -5s")
+	assert.Equal(t, time.Second, d, "invalid values should not change the destination")
+}
+
+func TestEnvVars_ShouldLeaveDestinationUnchangedWhenNotSet(t *testing.T) {
+	e := newTestEnv(map[string]string{"EMPTY": "  "})
+
+	s, d, b, i := "default", time.Second, true, 3
+	e.stringVar("EMPTY", &s)
+	e.durationVar("MISSING", &d)
+	e.boolVar("MISSING", &b)
+	e.intVar("MISSING", 0, 10, &i)
+
+	assert.Empty(t, e.errs)
+	assert.Equal(t, "default", s)
+	assert.Equal(t, time.Second, d)
+	assert.True(t, b)
+	assert.Equal(t, 3, i)
+}
+
+func TestEnvBool(t *testing.T) {
+	e := newTestEnv(map[string]string{"YES": "true", "NO": "0", "MAYBE": "maybe"})
+
+	var yes, no, maybe bool
+	e.boolVar("YES", &yes)
+	no = true
+	e.boolVar("NO", &no)
+	e.boolVar("MAYBE", &maybe)
+
+	assert.True(t, yes)
+	assert.False(t, no)
+	require.Len(t, e.errs, 1)
+	assert.EqualError(t, e.errs[0], `MAYBE is an invalid boolean value: "maybe"`)
+}
+
+func TestEnvInt_ShouldEnforceBounds(t *testing.T) {
+	e := newTestEnv(map[string]string{"OK": "5", "LOW": "-1", "HIGH": "11", "NAN": "five"})
+
+	var ok, low, high, nan int
+	e.intVar("OK", 0, 10, &ok)
+	e.intVar("LOW", 0, 10, &low)
+	e.intVar("HIGH", 0, 10, &high)
+	e.intVar("NAN", 0, 10, &nan)
+
+	assert.Equal(t, 5, ok)
+	require.Len(t, e.errs, 3)
+	assert.EqualError(t, e.errs[0], "LOW must be between 0 and 10, but was -1")
+	assert.EqualError(t, e.errs[1], "HIGH must be between 0 and 10, but was 11")
+	assert.EqualError(t, e.errs[2], `NAN is an invalid integer value: "five"`)
+}
+
+func TestEnvURLList(t *testing.T) {
+	e := newTestEnv(map[string]string{"URLS": "http://a:8080, http://b:8080", "BAD": "http://a,,http://b"})
+
+	var urls, bad []*url.URL
+	e.urlListVar("URLS", &urls)
+	e.urlListVar("BAD", &bad)
+
+	require.Len(t, urls, 2)
+	assert.Equal(t, "http://a:8080", urls[0].String())
+	assert.Equal(t, "http://b:8080", urls[1].String())
+	assert.Nil(t, bad)
+	require.Len(t, e.errs, 1)
+	assert.Contains(t, e.errs[0].Error(), "BAD environment variable contains an invalid URL")
+}
+
+func TestEnvAddr(t *testing.T) {
+	e := newTestEnv(map[string]string{"PORT_ONLY": ":9090", "HOST": "127.0.0.1:9090", "NO_PORT": "localhost", "BAD_PORT": ":http"})
+
+	var portOnly, host, noPort, badPort string
+	e.addrVar("PORT_ONLY", &portOnly)
+	e.addrVar("HOST", &host)
+	e.addrVar("NO_PORT", &noPort)
+	e.addrVar("BAD_PORT", &badPort)
+
+	assert.Equal(t, ":9090", portOnly)
+	assert.Equal(t, "127.0.0.1:9090", host)
+	require.Len(t, e.errs, 2)
+	assert.Contains(t, e.errs[0].Error(), "NO_PORT is an invalid address")
+	assert.Contains(t, e.errs[1].Error(), `BAD_PORT is an invalid address: invalid port "http"`)
+}
+
+func TestLoad_ShouldReadNewSettingsFromEnvironment(t *testing.T) {
+	cfg, err := Load(WithEnv(map[string]string{
+		flyteApiEnvName:            "http://localhost:8080",
+		flyteApiTimeOutEnvName:     "1500ms",
+		flytePollIntervalEnvName:   "250ms",
+		flyteMaxConcurrencyEnvName: "8",
+		flyteHealthAddrEnvName:     "127.0.0.1:9000",
+		flyteInsecureEnvName:       "true",
+	}))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, cfg.Timeout)
+	assert.Equal(t, 250*time.Millisecond, cfg.PollInterval)
+	assert.Equal(t, 8, cfg.MaxConcurrency)
+	assert.Equal(t, "127.0.0.1:9000", cfg.HealthAddr)
+	assert.True(t, cfg.Insecure)
+}
+
+func TestLoad_ShouldUseDefaultsForNewSettings(t *testing.T) {
+	cfg, err := Load(WithEnv(map[string]string{flyteApiEnvName: "http://localhost:8080"}))
+
+	require.NoError(t, err)
+	assert.Equal(t, pollIntervalDefault, cfg.PollInterval)
+	assert.Equal(t, 0, cfg.MaxConcurrency)
+	assert.Equal(t, ":8090", cfg.HealthAddr)
+	assert.False(t, cfg.Insecure)
+}
@@ -73,28 +73,45 @@ To assist with monitoring, you can add health checks to your pack in the followi
   }
 
   // now pass in to 'flyte.NewPack(...)'. when the pack is started this will also start a webserver ready to call your health checks.
-  // if no health checks are passed in the pack health check URL will always return a default healthy response.
+  // the pack also reports the health of its action pipeline with the built-in ActionPipeline check.
   p := flyte.NewPack(packDef, client, someCheck, otherCheck) // health checks are optional
 
 Then simply go to the pack health check URL i.e. 'http://localhost:8090' and you will be presented with a JSON response:
 
   {
-     "Some Check": {
-       "healthy": true,
-       "status": "All good"
-     },
-     "Other Check": {
-       "healthy": true,
-       "status": "Ok"
+     "state": "up",
+     "checks": {
+       "Some Check": {
+         "healthy": true,
+         "state": "up",
+         "status": "All good",
+         "critical": true,
+         "duration": "12µs",
+         "lastChanged": "2020-08-01T10:00:00Z"
+       },
+       "Other Check": {
+         "healthy": true,
+         "state": "up",
+         "status": "Ok",
+         "critical": true,
+         "duration": "9µs",
+         "lastChanged": "2020-08-01T10:00:00Z"
+       }
      }
   }
 
-The following HTTP header response codes will be returned depending on the status of the health checks:
+Each check is up, degraded or down, and is critical unless registered with NonCritical. The following HTTP header
+response codes will be returned depending on the state of the health checks:
 
-  * 200: All health checks passed.
-  * 500: One or more of the health checks failed. JSON results will be returned as normal in the response body.
+  * 200: The overall state is up or degraded.
+  * 503: A critical health check is down. JSON results will be returned as normal in the response body.
   * 500: JSON marshalling error.
 
+Checks passed to flyte.NewPack(...) are readiness checks, served on '/readyz'. Liveness and startup checks, registered
+with flyte.NewPackWithChecks(...), are served on '/livez' and '/startupz'. The pack is not ready until it has registered
+with flyte-api, or while it is draining in p.Shutdown(ctx). Shutdown is on the flyte.ShutdownPack interface returned by
+the pack constructors, not on flyte.Pack.
+
 A simple health check has been provided for you to check on the status of flyte-api: healthcheck.FlyteApiHealthCheck(c client.Client) and
 can be used in the following way:
 
@@ -107,6 +124,111 @@ can be used in the following way:
   p := flyte.NewPack(packDef, c, flyteApiHealthCheck)
   p.Start()
 
+Metrics
+
+Packs can record metrics about the actions they handle and the requests made to flyte-api, and serve them in the
+Prometheus text format at '/metrics' on the health check server. No metrics are recorded unless PackDef.Metrics is set.
+The metric names are the constants in the metrics package. To use another backend, implement metrics.Metrics and set
+it on the pack and the client:
+
+  m := metrics.NewPrometheus() // or your own metrics.Metrics
+  c := client.NewClient(flyteURL, 10 * time.Second, client.WithMetrics(m))
+  packDef.Metrics = m // served at '/metrics' when it is an http.Handler
+
+Tracing
+
+Packs record OpenTelemetry spans for TakeAction, handleAction, CompleteAction and PostEvent with a tracer of
+PackDef.TracerProvider, or of the global provider when it is not set. Requests to flyte-api carry a W3C 'traceparent'
+header, and the handleAction span continues the trace sent by flyte-api in the action metadata. Use
+Command.ContextHandler to receive the span of the action:
+
+  packDef.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+  command.ContextHandler = func(ctx context.Context, input json.RawMessage) flyte.Event {
+    trace.SpanFromContext(ctx).SetAttributes(attribute.String("jira.project", "FOO"))
+    ...
+  }
+
+Logging
+
+Packs log through logging.Logger, which takes a message and alternating keys and values like log/slog. Zerolog is the
+default. Set PackDef.Logger to log elsewhere; it is also used by the pack's client and health check server. Entries
+logged while handling an action have the pack, command and actionURL fields, and context handlers can log with them
+using logging.FromContext(ctx). Use logging.SetDefault(logging.Nop) to keep tests quiet.
+
+Redaction
+
+Events, packs and actions are written to logs and error messages as JSON with secrets masked, and truncated to 1024
+bytes. Fields named in redact.DefaultFields are masked by default. Set PackDef.Redactor to mask other field names or
+JSON paths:
+
+  packDef.Redactor = redact.Policy{Fields: append(redact.DefaultFields, "$.payload.card.number"), MaxSize: 4096}
+
+Proxy and connections
+
+The client uses the proxy environment variables by default. FLYTE_PROXY and FLYTE_NO_PROXY set a proxy for the flyte
+api only, and the connection pool is tuned with the FLYTE_DIAL_TIMEOUT, FLYTE_KEEP_ALIVE,
+FLYTE_MAX_IDLE_CONNS_PER_HOST, FLYTE_TLS_HANDSHAKE_TIMEOUT and FLYTE_HTTP2 environment variables, or with the
+client.WithProxy and client.WithTransport options.
+
+Retries
+
+Requests that fail with a connection error or a 429, 502, 503 or 504 response are retried with an exponential
+backoff, as set by the FLYTE_RETRY_* environment variables, honouring Retry-After. Requests such as TakeAction that
+flyte-api may have processed are only retried when it cannot have: the connection failed, or it responded 429 or 503.
+Clients created directly set a policy per operation with the client.WithRetryPolicy option.
+
+Events and action results are sent with their ID as the Idempotency-Key header, so flyte-api only processes them once
+when they are retried. An ID is generated unless Event.ID is set, e.g. to the ID of the message an event was observed
+from. They are retried like TakeAction unless flyte-api supports the header: it advertises an "idempotency" link, or
+the client.WithIdempotencySupport option is passed.
+
+Circuit breaker
+
+After FLYTE_CIRCUIT_FAILURE_THRESHOLD consecutive failed requests the client stops sending requests to flyte-api for
+FLYTE_CIRCUIT_OPEN_INTERVAL, and they fail fast with an error wrapping client.ErrCircuitOpen. The state is reported by
+the FlyteApiCircuitBreaker health check and the flyte_client_circuit_* metrics. Clients created directly enable it with
+the client.WithCircuitBreaker option.
+
+Event envelope
+
+Events can set an ID, OccurredAt, CorrelationID, Source and free-form Metadata, sent alongside the payload. The client
+only fills in the ID, the time the event occurred and the source, defaulting to the pack name, when they are not set.
+OccurredAt is sent as occurredAt, and createdAt is always the time the event is sent.
+
+CloudEvents
+
+FLYTE_EVENT_ENCODING sends events and action results as CloudEvents 1.0, in structured (cloudevents-structured) or
+binary (cloudevents-binary) mode. With negotiate the mode is chosen from the links flyte-api returns each time the pack
+is registered, falling back to the flyte format. The correlation ID and metadata are sent as extension attributes.
+
+Compression
+
+Request bodies of at least FLYTE_COMPRESSION_THRESHOLD bytes are gzipped once flyte-api advertises that it accepts
+gzip, and gzip encoded responses are decompressed. Responses larger than FLYTE_MAX_RESPONSE_SIZE fail with an error
+wrapping client.ErrResponseTooLarge. Both are off unless set. Clients created directly set these with the client.WithCompression and
+client.WithMaxResponseSize options.
+
+Failover
+
+FLYTE_API_FAILOVER names secondary flyte-api endpoints. After FLYTE_FAILOVER_THRESHOLD consecutive failed requests the
+client switches to the first of them that is available, reading its api links and registering the pack again, and
+switches back once the primary endpoint is healthy. The endpoint in use is reported by the FlyteApiEndpoint health
+check. Clients created directly enable it with the client.WithFailover option.
+
+Link refresh
+
+The flyte-api links, and the links of the registered pack, are discovered again once they are older than
+FLYTE_LINK_TTL, and when flyte-api responds 404 or 410 to the events or take action url, registering the pack again.
+The links are replaced together, so concurrent requests never use a mix of old and new links. Clients created
+directly set the ttl with the client.WithLinkTTL option.
+
+Link relations
+
+Links are found by their exact relation or, failing that, by the trailing path segments of a namespaced relation, so
+takeAction matches ".../swagger#!/action/takeAction" but not untakeAction. A relation matching links with different
+hrefs fails with an error wrapping client.ErrAmbiguousLink, and a missing one with client.ErrLinkNotFound. Relative
+hrefs are resolved against the document they were read from, and templated hrefs are expanded with the pack name.
+
 Help URLs
 
 You will notice that a `helpURL` field is present in 3 locations - PackDef, Command, and EventDef.
@@ -119,6 +241,14 @@ For a more complex pack you might want to deep link commands & event definitions
 The URL should link to a page that describes what a flow writer needs to know e.g. what the pack does, the format of the JSON in the event payloads and the format of the json for command inputs.
 It's up to the pack dev where and how they host their help docs.
 
+Schemas
+
+Commands can describe the JSON they expect as input (Command.InputSchema), and event definitions can describe the JSON of
+their payload (EventDef.PayloadSchema). Schemas are written by hand with flyte.NewSchema(...) or derived from a Go type with
+flyte.SchemaFor(...), and are published with the pack as 'inputSchema' and 'payloadSchema' links.
+When a command has an input schema, the action input is validated before the handler is invoked. If it does not match,
+the handler is not called and the action is completed with a FATAL event describing the violations.
+
 Example Pack
 
 The example below shows how to create a simplified "Jira Pack". The pack exposes a "createIssue" command allowing users to create tickets.
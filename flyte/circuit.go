@@ -0,0 +1,51 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+)
+
+const circuitCheckName = "FlyteApiCircuitBreaker"
+
+// reports the state of the client circuit breaker. The pack is degraded while the circuit is open or half-open, but
+// the check is not critical: the pack recovers by itself once flyte-api does
+func circuitHealthCheck(r client.CircuitStateReporter) healthcheck.Check {
+	return healthcheck.Check{
+		Kind:        healthcheck.Readiness,
+		Name:        circuitCheckName,
+		NonCritical: true,
+		Check: func() (string, healthcheck.Health) {
+			return circuitCheckName, circuitHealth(r)
+		},
+	}
+}
+
+func circuitHealth(r client.CircuitStateReporter) healthcheck.Health {
+	state, enabled := r.CircuitState()
+	switch {
+	case !enabled:
+		return healthcheck.Health{Healthy: true, Status: "disabled"}
+	case state == client.CircuitClosed:
+		return healthcheck.Health{Healthy: true, Status: state.String()}
+	case state == client.CircuitHalfOpen:
+		return healthcheck.Health{Healthy: true, State: healthcheck.StateDegraded, Status: "half-open: probing flyte api"}
+	default:
+		return healthcheck.Health{Healthy: false, Status: "open: requests to flyte api fail fast"}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCircuitHealthCheck_ShouldReportTheCircuitState(t *testing.T) {
+	cases := []struct {
+		reporter circuitReporter
+		healthy  bool
+		state    healthcheck.State
+		status   string
+	}{
+		{circuitReporter{enabled: false}, true, "", "disabled"},
+		{circuitReporter{client.CircuitClosed, true}, true, "", "closed"},
+		{circuitReporter{client.CircuitHalfOpen, true}, true, healthcheck.StateDegraded, "half-open: probing flyte api"},
+		{circuitReporter{client.CircuitOpen, true}, false, "", "open: requests to flyte api fail fast"},
+	}
+	for _, c := range cases {
+		check := circuitHealthCheck(c.reporter)
+
+		name, health := check.Check()
+
+		assert.Equal(t, circuitCheckName, name)
+		assert.True(t, check.NonCritical)
+		assert.Equal(t, c.healthy, health.Healthy, c.status)
+		assert.Equal(t, c.state, health.State, c.status)
+		assert.Equal(t, c.status, health.Status)
+	}
+}
+
+type circuitReporter struct {
+	state   client.CircuitState
+	enabled bool
+}
+
+func (r circuitReporter) CircuitState() (client.CircuitState, bool) {
+	return r.state, r.enabled
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package flyte
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ExpediaGroup/flyte-client/client"
-	"github.com/rs/zerolog/log"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/tracing"
+	"go.opentelemetry.io/otel/propagation"
+	"sort"
 	"time"
 )
 
 func (p pack) handleCommands() {
 	if len(p.Commands) > 0 {
+		p.pipeline.startPolling()
 		go p.handleCommandActions()
 	}
 }
@@ -32,75 +40,195 @@ func (p pack) handleCommands() {
 // repeatedly takes the next incoming action from the flyte server, passes to the appropriate handler and
 // sends the output event to the flyte server
 func (p pack) handleCommandActions() {
-	handlers := p.createHandlersMap()
+	commands := p.createCommandsMap()
+
+	// when limited, a slot must be free before the next action is taken so actions are not held while waiting
+	var slots chan struct{}
+	if p.maxConcurrency > 0 {
+		slots = make(chan struct{}, p.maxConcurrency)
+	}
 	for {
+		if slots != nil {
+			slots <- struct{}{}
+		}
+		// stop taking actions once the pack is draining
+		if !p.lifecycle.begin() {
+			return
+		}
 		a := p.getNextAction()
+		if a == nil {
+			p.lifecycle.end()
+			return
+		}
+		p.metrics().IncCounter(metrics.PackActionsTaken, metrics.Labels{"command": a.CommandName})
 		// concurrently handle the incoming actions
-		go p.handleAction(a, handlers)
+		p.pipeline.handling(1)
+		go func() {
+			defer p.lifecycle.end()
+			defer p.pipeline.handling(-1)
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			p.handleAction(a, commands)
+		}()
 	}
 }
 
-// creates map of commandName -> handler, so incoming actions can be routed easily
-func (p pack) createHandlersMap() map[string]CommandHandler {
-	handlers := make(map[string]CommandHandler)
+// creates map of commandName -> command, so incoming actions can be routed easily
+func (p pack) createCommandsMap() map[string]Command {
+	commands := make(map[string]Command)
 	for _, c := range p.Commands {
-		handlers[c.Name] = c.Handler
+		commands[c.Name] = c
 	}
-	return handlers
+	return commands
 }
 
-// gets the next action to process from the flyte server, if no action immediately available will start polling
+// gets the next action to process from the flyte server, if no action immediately available will start polling.
+// Returns nil if the pack starts draining while polling
 func (p pack) getNextAction() *client.Action {
 	for {
-		a, err := p.client.TakeAction()
+		a, err := p.takeAction()
+		p.pipeline.tookAction(err)
+		switch {
+		case err != nil:
+			p.metrics().IncCounter(metrics.PackPollErrors, nil)
+		case a == nil:
+			p.metrics().IncCounter(metrics.PackPollsIdle, nil)
+		}
 		if err != nil {
 			if _, ok := err.(client.NotFoundError); ok {
-				log.Fatal().Msg("Pack not found while polling for actions. Exiting.")
+				logging.Fatal(p.log(), "Pack not found while polling for actions. Exiting.")
+			}
+			if errors.Is(err, client.ErrCircuitOpen) {
+				p.log().Debug("not taking action while the flyte api circuit breaker is open")
+			} else {
+				p.log().Error("could not take action", logging.KeyError, err)
 			}
-			log.Err(err).Msg("could not take action")
 		}
 		if a == nil || err != nil {
-			time.Sleep(p.pollingFrequency)
+			select {
+			case <-p.lifecycle.stopping():
+				return nil
+			case <-time.After(p.pollingFrequency):
+			}
 			continue
 		}
 		return a
 	}
 }
 
+// takes the next action, if any, recording a span for the call
+func (p pack) takeAction() (*client.Action, error) {
+	ctx, span := p.startSpan(context.Background(), tracing.SpanTakeAction)
+	defer span.End()
+
+	a, err := p.contextClient().TakeActionContext(ctx)
+	tracing.RecordError(span, err)
+	if a != nil {
+		span.SetAttributes(tracing.AttributeCommand.String(a.CommandName))
+	}
+	return a, err
+}
+
 // invokes the relevant handler using the action input JSON and completes the action by posting the result to the flyte api
-// if no handler found, then the action will be completed using a fatal event
-func (p pack) handleAction(a *client.Action, handlers map[string]CommandHandler) {
+// if no handler found, or the input does not match the command input schema, then the action will be completed using a fatal event
+func (p pack) handleAction(a *client.Action, commands map[string]Command) {
+	// the span continues the trace of the flow when flyte-api sends its trace context with the action
+	ctx, span := p.startSpan(tracing.Extract(context.Background(), propagation.MapCarrier(a.Metadata)), tracing.SpanHandleAction)
+	defer span.End()
+	span.SetAttributes(tracing.AttributeCommand.String(a.CommandName))
+	// the logger is passed on in ctx, so handlers and the completion log with the action fields
+	logger := p.log().With(logging.KeyCommand, a.CommandName)
+	if u, err := actionURL(a); err != nil {
+		logger.Warn("cannot find where to post the action result", logging.KeyError, err)
+	} else {
+		logger = logger.With(logging.KeyActionURL, u)
+	}
+	ctx = logging.ContextWithLogger(ctx, logger)
+
 	// ensure that a panicking CommandHandler is captured and handled
-	defer p.handlePanic(a)
+	defer p.handlePanic(ctx, a)
 
-	handler, ok := handlers[a.CommandName]
+	command, ok := commands[a.CommandName]
 	if !ok {
-		err := fmt.Errorf("no handler could be found for command %q in %v", a.CommandName, handlers)
-		p.completeAction(a, NewFatalEvent(err.Error()))
-		log.Err(err).Send()
+		err := fmt.Errorf("no handler could be found for command %q in %v", a.CommandName, commandNames(commands))
+		p.completeAction(ctx, a, NewFatalEvent(err.Error()))
+		logger.Error("cannot handle action", logging.KeyError, err)
 		return
 	}
 
-	outputEvent := handler(a.Input)
-	p.completeAction(a, outputEvent)
+	if command.InputSchema != nil {
+		if err := command.InputSchema.Validate(a.Input); err != nil {
+			err = fmt.Errorf("input for command %q does not match its schema: %v", a.CommandName, err)
+			p.completeAction(ctx, a, NewFatalEvent(err.Error()))
+			logger.Error("cannot handle action", logging.KeyError, err)
+			return
+		}
+	}
+
+	start := time.Now()
+	outputEvent := command.handle(ctx, a.Input)
+	p.metrics().ObserveDuration(metrics.PackHandlerDuration, metrics.Labels{"command": a.CommandName}, time.Since(start))
+	p.completeAction(ctx, a, outputEvent)
+}
+
+// the url the action result is posted to, used to identify the action in logs. Returns a *client.LinkError if the
+// action has no result link, or more than one
+func actionURL(a *client.Action) (string, error) {
+	u, err := a.ResultURL()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// invokes the context handler if set, otherwise the handler
+func (c Command) handle(ctx context.Context, input json.RawMessage) Event {
+	if c.ContextHandler != nil {
+		return c.ContextHandler(ctx, input)
+	}
+	return c.Handler(input)
+}
+
+// returns the names of the commands, used when reporting an unknown command
+func commandNames(commands map[string]Command) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // used to ensure panicing command handlers can be recovered gracefully by completing the action with a new fatal event
 // populated by the error message returned
-func (p pack) handlePanic(a *client.Action) {
+func (p pack) handlePanic(ctx context.Context, a *client.Action) {
 	if r := recover(); r != nil {
-		p.completeAction(a, NewFatalEvent(fmt.Sprintf("%v", r)))
-		log.Error().Msgf("command handler for %q raised a panic: %s", a.CommandName, r)
+		p.metrics().IncCounter(metrics.PackPanicsRecovered, metrics.Labels{"command": a.CommandName})
+		tracing.RecordError(tracing.SpanFromContext(ctx), fmt.Errorf("command handler raised a panic: %v", r))
+		p.completeAction(ctx, a, NewFatalEvent(fmt.Sprintf("%v", r)))
+		logging.FromContext(ctx).Error(fmt.Sprintf("command handler for %q raised a panic: %s", a.CommandName, p.redact(fmt.Sprint(r))))
 	}
 }
 
-// completes the action by posting an event to the flyte api
-func (p pack) completeAction(a *client.Action, event Event) {
-	e := client.Event{
-		Name:    event.EventDef.Name,
-		Payload: event.Payload,
+// completes the action by posting an event to the flyte api. The event is recorded on the action span in ctx
+func (p pack) completeAction(ctx context.Context, a *client.Action, event Event) {
+	e := event.clientEvent()
+	if e.Name == fatalEventName {
+		p.metrics().IncCounter(metrics.PackFatalEvents, metrics.Labels{"command": a.CommandName})
 	}
-	if err := p.client.CompleteAction(*a, e); err != nil {
-		log.Err(err).Msgf("could not complete action %+v with event %+v", a, e)
+	tracing.SpanFromContext(ctx).SetAttributes(tracing.AttributeEvent.String(e.Name))
+
+	ctx, span := p.startSpan(ctx, tracing.SpanCompleteAction)
+	defer span.End()
+	span.SetAttributes(tracing.AttributeCommand.String(a.CommandName), tracing.AttributeEvent.String(e.Name))
+
+	err := p.contextClient().CompleteActionContext(ctx, *a, e)
+	tracing.RecordError(span, err)
+	p.pipeline.completedAction(err)
+	if err != nil {
+		logging.FromContext(ctx).Error("could not complete action", logging.KeyError, err, logging.KeyEvent, p.redact(e))
+		return
 	}
+	p.metrics().IncCounter(metrics.PackActionsCompleted, metrics.Labels{"command": a.CommandName})
 }
@@ -17,10 +17,20 @@ limitations under the License.
 package flyte
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 )
@@ -100,3 +110,309 @@ func (mockClient) CompleteAction(client.Action, client.Event) error {
 func (mockClient) GetFlyteHealthCheckURL() (*url.URL, error) {
 	return nil, nil
 }
+
+func TestHandleActionShouldCompleteWithFatalEventAndNotCallHandler_WhenInputDoesNotMatchSchema(t *testing.T) {
+	schema, err := NewSchema([]byte(`{"type": "object", "required": ["project"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handlerCalled := false
+	command := Command{
+		Name:        "createIssue",
+		InputSchema: schema,
+		Handler: func(input json.RawMessage) Event {
+			handlerCalled = true
+			return Event{}
+		},
+	}
+
+	var completedWith client.Event
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		completedWith = e
+		return nil
+	}}
+	p := pack{client: mock}
+
+	p.handleAction(&client.Action{CommandName: "createIssue", Input: json.RawMessage(`{}`)}, map[string]Command{command.Name: command})
+
+	assert.False(t, handlerCalled)
+	assert.Equal(t, fatalEventName, completedWith.Name)
+	assert.Equal(t, `input for command "createIssue" does not match its schema: $.project: is required`, completedWith.Payload)
+}
+
+func TestHandleActionShouldCallHandler_WhenInputMatchesSchema(t *testing.T) {
+	schema, err := NewSchema([]byte(`{"type": "object", "required": ["project"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command := Command{
+		Name:        "createIssue",
+		InputSchema: schema,
+		Handler: func(input json.RawMessage) Event {
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		},
+	}
+
+	var completedWith client.Event
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		completedWith = e
+		return nil
+	}}
+	p := pack{client: mock}
+
+	p.handleAction(&client.Action{CommandName: "createIssue", Input: json.RawMessage(`{"project": "FOO"}`)}, map[string]Command{command.Name: command})
+
+	assert.Equal(t, "IssueCreated", completedWith.Name)
+}
+
+func TestHandleActionShouldCallHandler_WhenInputSchemaIsOnlyHosted(t *testing.T) {
+	schemaURL, _ := url.Parse("http://jirapack/schemas/create-issue.json")
+	command := Command{
+		Name:        "createIssue",
+		InputSchema: &Schema{URL: schemaURL},
+		Handler: func(input json.RawMessage) Event {
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		},
+	}
+
+	var completedWith client.Event
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		completedWith = e
+		return nil
+	}}
+	p := pack{client: mock}
+
+	p.handleAction(&client.Action{CommandName: "createIssue", Input: json.RawMessage(`{"project": "FOO"}`)}, map[string]Command{command.Name: command})
+
+	assert.Equal(t, "IssueCreated", completedWith.Name)
+}
+
+func TestHandleActionShouldRecordMetrics(t *testing.T) {
+	// given a pack recording metrics
+	m := metrics.NewPrometheus()
+	commands := map[string]Command{
+		"createIssue": {Name: "createIssue", Handler: func(input json.RawMessage) Event {
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		}},
+		"closeIssue": {Name: "closeIssue", Handler: func(input json.RawMessage) Event {
+			panic("boom")
+		}},
+	}
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		return nil
+	}}
+	p := pack{PackDef: PackDef{Metrics: m}, client: mock}
+
+	// when
+	p.handleAction(&client.Action{CommandName: "createIssue"}, commands)
+	p.handleAction(&client.Action{CommandName: "closeIssue"}, commands)
+
+	// then
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, `flyte_pack_actions_completed_total{command="createIssue"} 1`)
+	assert.Contains(t, body, `flyte_pack_actions_completed_total{command="closeIssue"} 1`)
+	assert.Contains(t, body, `flyte_pack_handler_duration_seconds_count{command="createIssue"} 1`)
+	assert.Contains(t, body, `flyte_pack_panics_recovered_total{command="closeIssue"} 1`)
+	assert.Contains(t, body, `flyte_pack_fatal_events_total{command="closeIssue"} 1`)
+	assert.NotContains(t, body, `flyte_pack_fatal_events_total{command="createIssue"}`)
+}
+
+func TestHandleActionShouldLogWithPackCommandAndActionFields(t *testing.T) {
+	// given a handler that logs with the logger of the action
+	command := Command{
+		Name: "createIssue",
+		ContextHandler: func(ctx context.Context, input json.RawMessage) Event {
+			logging.FromContext(ctx).Info("creating issue")
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		},
+	}
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		return errors.New("rejected")
+	}}
+	logger := newRecordingLogger()
+	p := pack{PackDef: PackDef{Name: "JiraPack", Logger: logger}, client: mock}
+	resultURL, _ := url.Parse("http://example.com/v1/packs/JiraPack/actions/1/result")
+
+	// when
+	p.handleAction(&client.Action{CommandName: "createIssue", Links: []client.Link{{Href: resultURL, Rel: "http://example.com/swagger#!/action/actionResult"}}}, map[string]Command{command.Name: command})
+
+	// then
+	entries := logger.recorded()
+	require.Len(t, entries, 2)
+	fields := []interface{}{logging.KeyPack, "JiraPack", logging.KeyCommand, "createIssue", logging.KeyActionURL, resultURL.String()}
+	assert.Equal(t, "creating issue", entries[0].msg)
+	assert.Equal(t, fields, entries[0].keyvals)
+	assert.Equal(t, "could not complete action", entries[1].msg)
+	assert.Equal(t, fields, entries[1].keyvals[:6])
+	assert.Equal(t, []interface{}{logging.KeyError, errors.New("rejected")}, entries[1].keyvals[6:8])
+}
+
+func TestHandleActionShouldLogTheLinkError_WhenTheActionHasNoResultLink(t *testing.T) {
+	// given
+	command := Command{
+		Name:    "createIssue",
+		Handler: func(input json.RawMessage) Event { return Event{EventDef: EventDef{Name: "IssueCreated"}} },
+	}
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		return nil
+	}}
+	logger := newRecordingLogger()
+	p := pack{PackDef: PackDef{Name: "JiraPack", Logger: logger}, client: mock}
+
+	// when
+	p.handleAction(&client.Action{CommandName: "createIssue"}, map[string]Command{command.Name: command})
+
+	// then
+	entries := logger.recorded()
+	require.NotEmpty(t, entries)
+	assert.Equal(t, "cannot find where to post the action result", entries[0].msg)
+	assert.Equal(t, []interface{}{logging.KeyPack, "JiraPack", logging.KeyCommand, "createIssue"}, entries[0].keyvals[:4])
+	var linkErr *client.LinkError
+	require.True(t, errors.As(entries[0].keyvals[5].(error), &linkErr))
+	assert.Equal(t, "actionResult", linkErr.Rel)
+}
+
+func TestCompleteActionShouldLogRedactedEvent_WhenActionCannotBeCompleted(t *testing.T) {
+	// given
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		return errors.New("rejected")
+	}}
+	logger := newRecordingLogger()
+	p := pack{PackDef: PackDef{Logger: logger, Redactor: redact.Policy{Fields: []string{"$.payload.apiKey"}}}, client: mock}
+	ctx := logging.ContextWithLogger(context.Background(), logger)
+
+	// when
+	p.completeAction(ctx, &client.Action{CommandName: "deploy"}, Event{EventDef: EventDef{Name: "Deployed"}, Payload: map[string]string{"apiKey": "k3y"}})
+
+	// then
+	entries := logger.recorded()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []interface{}{logging.KeyEvent, `{"createdAt":"0001-01-01T00:00:00Z","event":"Deployed","occurredAt":"0001-01-01T00:00:00Z","payload":{"apiKey":"[REDACTED]"}}`}, entries[0].keyvals[2:4])
+}
+
+func TestCompleteActionShouldSendTheEventEnvelopeFields(t *testing.T) {
+	// given
+	var got client.Event
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		got = e
+		return nil
+	}}
+	p := pack{client: mock}
+	occurredAt := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	// when
+	p.completeAction(context.Background(), &client.Action{CommandName: "deploy"}, Event{
+		EventDef:      EventDef{Name: "Deployed"},
+		Payload:       "v1.2",
+		ID:            "deploy-42-result",
+		OccurredAt:    occurredAt,
+		CorrelationID: "deploy-42",
+		Source:        "ci",
+		Metadata:      map[string]string{"schemaVersion": "2"},
+	})
+
+	// then
+	assert.Equal(t, client.Event{
+		ID:            "deploy-42-result",
+		Name:          "Deployed",
+		Payload:       "v1.2",
+		OccurredAt:    occurredAt,
+		CorrelationID: "deploy-42",
+		Source:        "ci",
+		Metadata:      map[string]string{"schemaVersion": "2"},
+	}, got)
+}
+
+func TestHandleCommandActionsShouldNotHandleMoreThanMaxConcurrencyActionsAtOnce(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight, handled := 0, 0, 0
+	done := make(chan bool)
+	command := Command{
+		Name: "build",
+		Handler: func(input json.RawMessage) Event {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			handled++
+			if handled == 6 {
+				close(done)
+			}
+			mu.Unlock()
+			return Event{}
+		},
+	}
+
+	mock := mockClient{takeAction: func() (*client.Action, error) {
+		return &client.Action{CommandName: "build"}, nil
+	}}
+	p := pack{PackDef: PackDef{Commands: []Command{command}}, client: mock, pollingFrequency: time.Millisecond, maxConcurrency: 2}
+
+	go p.handleCommandActions()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for actions to be handled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, maxInFlight)
+}
+
+type completingMockClient struct {
+	mockClient
+	completeAction func(client.Action, client.Event) error
+}
+
+func (m completingMockClient) CompleteAction(a client.Action, e client.Event) error {
+	return m.completeAction(a, e)
+}
+
+// records the entries logged, with the fields of the logger
+type recordingLogger struct {
+	mu      *sync.Mutex
+	entries *[]logEntry
+	keyvals []interface{}
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+type logEntry struct {
+	msg     string
+	keyvals []interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record(msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.record(msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record(msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.record(msg, keyvals) }
+
+func (l *recordingLogger) With(keyvals ...interface{}) logging.Logger {
+	return &recordingLogger{mu: l.mu, entries: l.entries, keyvals: append(append([]interface{}(nil), l.keyvals...), keyvals...)}
+}
+
+func (l *recordingLogger) record(msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, logEntry{msg: msg, keyvals: append(append([]interface{}(nil), l.keyvals...), keyvals...)})
+}
+
+func (l *recordingLogger) recorded() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]logEntry(nil), *l.entries...)
+}
@@ -50,7 +50,10 @@ func processCommands(commands []Command, eventDefsSet map[string]client.EventDef
 			EventNames: processCommandEventDefs(command.OutputEvents, eventDefsSet),
 		}
 		if command.HelpURL != nil {
-			clientCommand.Links = []client.Link{createLink(command.HelpURL, "help")}
+			clientCommand.Links = append(clientCommand.Links, createLink(command.HelpURL, "help"))
+		}
+		if command.InputSchema != nil {
+			clientCommand.Links = append(clientCommand.Links, command.InputSchema.link(inputSchemaRel))
 		}
 		c[i] = clientCommand
 	}
@@ -74,11 +77,18 @@ func processEventDefs(eventDefs []EventDef, eventDefsSet map[string]client.Event
 	}
 }
 
-// creates a client EventDef from a flyte EventDef passed in to it, and adds it to the eventDefsSet also passed in
+// creates a client EventDef from a flyte EventDef passed in to it, and adds it to the eventDefsSet also passed in.
+// If the same event is defined more than once, a definition with links is not replaced by one without
 func addToEventDefsSet(eventDef EventDef, eventDefsSet map[string]client.EventDef) {
 	clientEventDef := client.EventDef{Name: eventDef.Name}
 	if eventDef.HelpURL != nil {
-		clientEventDef.Links = []client.Link{createLink(eventDef.HelpURL, "help")}
+		clientEventDef.Links = append(clientEventDef.Links, createLink(eventDef.HelpURL, "help"))
+	}
+	if eventDef.PayloadSchema != nil {
+		clientEventDef.Links = append(clientEventDef.Links, eventDef.PayloadSchema.link(payloadSchemaRel))
+	}
+	if existing, ok := eventDefsSet[eventDef.Name]; ok && len(clientEventDef.Links) == 0 {
+		clientEventDef = existing
 	}
 	eventDefsSet[eventDef.Name] = clientEventDef
 }
@@ -0,0 +1,51 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"github.com/ExpediaGroup/flyte-client/redact"
+)
+
+const endpointCheckName = "FlyteApiEndpoint"
+
+// reports the flyte api endpoint the client sends requests to, with the password of its url masked. The pack is degraded while failed over to a secondary
+// endpoint, but the check is not critical: the pack keeps working, and switches back once the primary recovers
+func endpointHealthCheck(r client.EndpointReporter) healthcheck.Check {
+	return healthcheck.Check{
+		Kind:        healthcheck.Readiness,
+		Name:        endpointCheckName,
+		NonCritical: true,
+		Check: func() (string, healthcheck.Health) {
+			return endpointCheckName, endpointHealth(r)
+		},
+	}
+}
+
+func endpointHealth(r client.EndpointReporter) healthcheck.Health {
+	endpoint, primary := r.ActiveEndpoint()
+	switch {
+	case endpoint == nil:
+		return healthcheck.Health{Healthy: true, Status: "unknown"}
+	case primary:
+		return healthcheck.Health{Healthy: true, Status: fmt.Sprintf("primary: %s", redact.URL(endpoint))}
+	default:
+		return healthcheck.Health{Healthy: true, State: healthcheck.StateDegraded, Status: fmt.Sprintf("failed over to secondary: %s", redact.URL(endpoint))}
+	}
+}
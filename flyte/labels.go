@@ -0,0 +1,130 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Determines how the labels defined in code (PackDef.Labels) are combined with the labels set in the FLYTE_LABELS
+// environment variable when creating a default pack.
+type LabelMergeStrategy int
+
+const (
+	// environment labels replace code labels with the same key. This is the default
+	EnvironmentOverrides LabelMergeStrategy = iota
+	// code labels are kept when an environment label has the same key
+	CodeWins
+	// a label key defined both in code and in the environment with different values is an error
+	ErrorOnConflict
+)
+
+const maxLabelLength = 63
+
+// label keys and values must start and end with an alphanumeric character and can contain '-', '_' and '.' in between
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]*[A-Za-z0-9])?$`)
+
+func (s LabelMergeStrategy) String() string {
+	switch s {
+	case EnvironmentOverrides:
+		return "EnvironmentOverrides"
+	case CodeWins:
+		return "CodeWins"
+	case ErrorOnConflict:
+		return "ErrorOnConflict"
+	default:
+		return fmt.Sprintf("LabelMergeStrategy(%d)", int(s))
+	}
+}
+
+// merges the code and environment labels using the strategy passed in. The result is not validated, see validateLabels
+func mergeLabels(codeLabels, envLabels map[string]string, strategy LabelMergeStrategy) (map[string]string, error) {
+	merged := make(map[string]string)
+	for k, v := range codeLabels {
+		merged[k] = v
+	}
+
+	var conflicts []string
+	for k, v := range envLabels {
+		codeValue, defined := codeLabels[k]
+		if !defined || codeValue == v {
+			merged[k] = v
+			continue
+		}
+		switch strategy {
+		case EnvironmentOverrides:
+			merged[k] = v
+		case CodeWins:
+		case ErrorOnConflict:
+			conflicts = append(conflicts, fmt.Sprintf("%q (code: %q, environment: %q)", k, codeValue, v))
+		default:
+			return nil, fmt.Errorf("unknown label merge strategy %v", strategy)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("conflicting pack labels: %s", strings.Join(conflicts, ", "))
+	}
+	return merged, nil
+}
+
+// checks every label key and value is between 1 and 63 characters and matches the label pattern
+func validateLabels(labels map[string]string) error {
+	var invalid []string
+	for k, v := range labels {
+		if err := validateLabelPart(k); err != nil {
+			invalid = append(invalid, fmt.Sprintf("key %q %s", k, err))
+		}
+		if err := validateLabelPart(v); err != nil {
+			invalid = append(invalid, fmt.Sprintf("value %q of key %q %s", v, k, err))
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("invalid pack labels: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+func validateLabelPart(s string) error {
+	if len(s) == 0 || len(s) > maxLabelLength {
+		return fmt.Errorf("must be between 1 and %d characters", maxLabelLength)
+	}
+	if !labelPattern.MatchString(s) {
+		return errors.New("must start and end with an alphanumeric character and only contain alphanumerics, '-', '_' or '.'")
+	}
+	return nil
+}
+
+// reports the effective pack labels on the health endpoint. This check is always healthy, but it is degraded with the
+// error instead of the labels if the labels are invalid or cannot be merged
+func labelsHealthCheck(labels map[string]string, labelsErr error) healthcheck.HealthCheck {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return func() (name string, health healthcheck.Health) {
+		if labelsErr != nil {
+			return "PackLabels", healthcheck.Health{Healthy: true, State: healthcheck.StateDegraded, Status: labelsErr.Error()}
+		}
+		return "PackLabels", healthcheck.Health{Healthy: true, Status: labels}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"errors"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var (
+	codeLabels = map[string]string{"env": "dev", "team": "flyte"}
+	envLabels  = map[string]string{"env": "prod", "region": "eu-west-1"}
+)
+
+func TestMergeLabels_EnvironmentOverrides(t *testing.T) {
+	labels, err := mergeLabels(codeLabels, envLabels, EnvironmentOverrides)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "flyte", "region": "eu-west-1"}, labels)
+}
+
+func TestMergeLabels_CodeWins(t *testing.T) {
+	labels, err := mergeLabels(codeLabels, envLabels, CodeWins)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "dev", "team": "flyte", "region": "eu-west-1"}, labels)
+}
+
+func TestMergeLabels_ErrorOnConflict(t *testing.T) {
+	_, err := mergeLabels(codeLabels, envLabels, ErrorOnConflict)
+
+	assert.EqualError(t, err, `conflicting pack labels: "env" (code: "dev", environment: "prod")`)
+}
+
+func TestMergeLabels_ErrorOnConflictShouldAllowTheSameValue(t *testing.T) {
+	labels, err := mergeLabels(codeLabels, map[string]string{"env": "dev"}, ErrorOnConflict)
+
+	require.NoError(t, err)
+	assert.Equal(t, codeLabels, labels)
+}
+
+func TestMergeLabels_ShouldNotModifyTheCodeLabels(t *testing.T) {
+	_, err := mergeLabels(codeLabels, envLabels, EnvironmentOverrides)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "dev", "team": "flyte"}, codeLabels)
+}
+
+func TestMergeLabels_ShouldReturnEmptyLabelsWhenNoneAreDefined(t *testing.T) {
+	labels, err := mergeLabels(nil, nil, EnvironmentOverrides)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, labels)
+}
+
+func TestValidateLabels_ShouldRejectInvalidLabels(t *testing.T) {
+	err := validateLabels(map[string]string{"-env": "prod", "team": "", "ok": strings.Repeat("a", 64)})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `key "-env" must start and end with an alphanumeric character`)
+	assert.Contains(t, err.Error(), `value "" of key "team" must be between 1 and 63 characters`)
+	assert.Contains(t, err.Error(), `of key "ok" must be between 1 and 63 characters`)
+}
+
+func TestValidateLabels_ShouldAcceptValidLabels(t *testing.T) {
+	assert.NoError(t, validateLabels(map[string]string{"env": "prod", "app.version": "1.2.3", "a": "b_c-d"}))
+}
+
+func TestLabelsHealthCheck_ShouldReportLabels(t *testing.T) {
+	name, health := labelsHealthCheck(map[string]string{"env": "prod"}, nil)()
+
+	assert.Equal(t, "PackLabels", name)
+	assert.True(t, health.Healthy)
+	assert.Empty(t, health.State)
+	assert.Equal(t, map[string]string{"env": "prod"}, health.Status)
+}
+
+func TestLabelsHealthCheck_ShouldBeDegradedWithTheLabelsError(t *testing.T) {
+	_, health := labelsHealthCheck(map[string]string{"env": "prod"}, errors.New("invalid pack labels"))()
+
+	assert.True(t, health.Healthy)
+	assert.Equal(t, healthcheck.StateDegraded, health.State)
+	assert.Equal(t, "invalid pack labels", health.Status)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"context"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"sync"
+)
+
+const (
+	registeringStatus = "registering with flyte api"
+	registeredStatus  = "registered with flyte api"
+	drainingStatus    = "draining"
+)
+
+// lifecycle is the state shared by every copy of a pack. It reports whether the pack has started and is ready,
+// and tracks the actions being taken or handled so they can be drained on shutdown.
+// A nil lifecycle is valid - the pack is never drained and its state is not reported.
+type lifecycle struct {
+	started  *healthcheck.Gate // startup: healthy once the pack has registered
+	ready    *healthcheck.Gate // readiness: healthy once the pack has registered, until it is drained
+	mu       sync.Mutex
+	server   *healthcheck.Server
+	draining bool
+	inFlight int
+	stop     chan struct{} // closed when draining starts, so polling for actions stops
+	drained  chan struct{} // closed when draining and there are no actions in flight
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		started: healthcheck.NewGate("PackStarted", healthcheck.Health{Healthy: false, Status: registeringStatus}),
+		ready:   healthcheck.NewGate("PackReady", healthcheck.Health{Healthy: false, Status: registeringStatus}),
+		stop:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+// the checks reporting the pack state, registered on the health check server
+func (l *lifecycle) healthChecks() []healthcheck.Check {
+	if l == nil {
+		return nil
+	}
+	return []healthcheck.Check{
+		{Kind: healthcheck.Startup, Check: l.started.Check},
+		{Kind: healthcheck.Readiness, Check: l.ready.Check},
+	}
+}
+
+func (l *lifecycle) setServer(s *healthcheck.Server) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.server = s
+}
+
+// marks the pack as started and, unless it is already draining, ready
+func (l *lifecycle) registered() {
+	if l == nil {
+		return
+	}
+	l.started.Set(true, registeredStatus)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.draining {
+		l.ready.Set(true, registeredStatus)
+	}
+}
+
+// returns a channel that is closed when the pack starts draining. Nil, which never closes, for a nil lifecycle
+func (l *lifecycle) stopping() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	return l.stop
+}
+
+// called before taking an action. Returns false if the pack is draining and no more actions should be taken.
+// Every successful call must be matched by a call to end once the action has been handled, or none was taken
+func (l *lifecycle) begin() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.draining {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *lifecycle) end() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if l.draining && l.inFlight == 0 {
+		close(l.drained)
+	}
+}
+
+// marks the pack as not ready, stops taking actions and waits for the actions in flight to be handled, then stops
+// the health check server. Returns the context error if the context is done first
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if !l.draining {
+		l.draining = true
+		l.ready.Set(false, drainingStatus)
+		close(l.stop)
+		if l.inFlight == 0 {
+			close(l.drained)
+		}
+	}
+	server := l.server
+	l.mu.Unlock()
+
+	select {
+	case <-l.drained:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if server != nil {
+		return server.Shutdown(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLifecycle_ShouldNotBeReadyOrStarted_UntilRegistered(t *testing.T) {
+	// given
+	l := newLifecycle()
+
+	// then
+	_, ready := l.ready.Check()
+	_, started := l.started.Check()
+	assert.False(t, ready.Healthy)
+	assert.False(t, started.Healthy)
+
+	// when
+	l.registered()
+
+	// then
+	_, ready = l.ready.Check()
+	_, started = l.started.Check()
+	assert.True(t, ready.Healthy)
+	assert.True(t, started.Healthy)
+}
+
+func TestLifecycle_ShouldNotBeReady_WhileDraining(t *testing.T) {
+	// given
+	l := newLifecycle()
+	l.registered()
+	require.True(t, l.begin())
+
+	// when an action is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := l.shutdown(ctx)
+
+	// then
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, ready := l.ready.Check()
+	assert.False(t, ready.Healthy)
+	assert.Equal(t, drainingStatus, ready.Status)
+	_, started := l.started.Check()
+	assert.True(t, started.Healthy)
+	assert.False(t, l.begin())
+}
+
+func TestShutdown_ShouldWaitForActionsInProgress(t *testing.T) {
+	StartHealthCheckServer = false // we need this to stop multiple registrations of the healthcheck server
+
+	// given a command handler that blocks until released
+	release := make(chan struct{})
+	handling := make(chan struct{})
+	command := Command{
+		Name: "RunBuild",
+		Handler: func(input json.RawMessage) Event {
+			close(handling)
+			<-release
+			return Event{EventDef: EventDef{Name: "BuildStarted"}}
+		},
+	}
+	var taken, completed int32
+	c := MockClient{
+		createPack: func(p client.Pack) error {
+			return nil
+		},
+		takeAction: func() (*client.Action, error) {
+			if atomic.AddInt32(&taken, 1) == 1 {
+				return &client.Action{CommandName: command.Name}, nil
+			}
+			return nil, nil
+		},
+		completeAction: func(action client.Action, event client.Event) error {
+			atomic.AddInt32(&completed, 1)
+			return nil
+		},
+	}
+	p := NewPack(PackDef{Name: "BambooPack", Commands: []Command{command}}, c).(pack)
+	p.pollingFrequency = 10 * time.Millisecond
+	p.Start()
+	<-handling
+
+	// when
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- p.Shutdown(context.Background())
+	}()
+
+	// then shutdown waits for the action to complete
+	select {
+	case <-shutdown:
+		assert.Fail(t, "shutdown should wait for the action in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "shutdown should complete once the action is handled")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&completed))
+
+	// and no more actions are taken
+	takenAtShutdown := atomic.LoadInt32(&taken)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, takenAtShutdown, atomic.LoadInt32(&taken))
+}
+
+func TestShutdown_ShouldBeSafe_ForPackWithoutLifecycle(t *testing.T) {
+	p := pack{}
+
+	assert.NoError(t, p.Shutdown(context.Background()))
+}
@@ -19,18 +19,25 @@ limitations under the License.
 package flyte
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/ExpediaGroup/flyte-client/client"
 	"github.com/ExpediaGroup/flyte-client/config"
 	"github.com/ExpediaGroup/flyte-client/healthcheck"
-	"github.com/rs/zerolog/log"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"github.com/ExpediaGroup/flyte-client/metrics"
+	"github.com/ExpediaGroup/flyte-client/redact"
+	"github.com/ExpediaGroup/flyte-client/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
 	"net/url"
 	"time"
 )
 
 const (
-	fatalEventName    = "FATAL"
-	registerRetryWait = 3 * time.Second
+	fatalEventName = "FATAL"
+	retryJitter    = 0.2 // the fraction of the wait between retries of client requests that is randomised
 )
 
 type Pack interface {
@@ -42,16 +49,48 @@ type Pack interface {
 	SendEvent(Event) error
 }
 
+// ShutdownPack is a Pack that can be shut down gracefully. The pack constructors return it, and as it embeds Pack
+// existing implementations of Pack are not affected.
+type ShutdownPack interface {
+	Pack
+
+	// Shutdown marks the pack as not ready, stops taking actions and waits for the actions in progress to complete,
+	// then stops the health check server. If the context is done first, the context error is returned.
+	Shutdown(ctx context.Context) error
+}
+
 type pack struct {
 	PackDef
 	client           client.Client
 	pollingFrequency time.Duration
-	healthChecks     []healthcheck.HealthCheck
+	maxConcurrency   int    // the maximum number of actions handled at the same time, 0 means unlimited
+	healthAddr       string // the address the health check server listens on, defaults to healthcheck.Port on all interfaces
+	healthOptions    []healthcheck.ServerOption
+	healthChecks     []healthcheck.Check
+	labelsErr        error              // why the labels are invalid or could not be merged, reported by the PackLabels health check
+	registerRetry    client.RetryPolicy // the backoff between attempts to register the pack
+	lifecycle        *lifecycle
+	pipeline         *pipeline
 }
 
 // Creates a Pack struct with the details from the pack definition and a connection to the flyte api through the client.
-// Optionally, you can also pass in pack health checks
-func NewPack(packDef PackDef, client client.Client, healthChecks ...healthcheck.HealthCheck) Pack {
+// Optionally, you can also pass in pack health checks. These are readiness checks, use NewPackWithChecks for other kinds
+func NewPack(packDef PackDef, client client.Client, healthChecks ...healthcheck.HealthCheck) ShutdownPack {
+	checks := make([]healthcheck.Check, len(healthChecks))
+	for i, hc := range healthChecks {
+		checks[i] = healthcheck.Check{Kind: healthcheck.Readiness, Check: hc}
+	}
+	return NewPackWithChecks(packDef, client, checks...)
+}
+
+// Creates a Pack in the same way as NewPack, with health checks of any kind.
+func NewPackWithChecks(packDef PackDef, client client.Client, healthChecks ...healthcheck.Check) ShutdownPack {
+	// invalid labels are still used, as packs registered them before they were validated
+	labelsErr := validateLabels(packDef.Labels)
+	if labelsErr != nil {
+		logger := logging.OrDefault(packDef.Logger).With(logging.KeyPack, packDef.Name)
+		logger.Warn(fmt.Sprintf("%s pack labels are not valid", packDef.Name), logging.KeyError, labelsErr)
+	}
 	return pack{
 		PackDef: packDef,
 		client:  client,
@@ -60,100 +99,291 @@ func NewPack(packDef PackDef, client client.Client, healthChecks ...healthcheck.
 		// (bearing in mind that this polling rate only comes into play if no actions are immediately available
 		// - if actions are available then the pack/client will consume them as quickly as it can)
 		pollingFrequency: 5 * time.Second,
-		healthChecks:     addDefaultHealthCheckIfNoneExist(healthChecks),
+		healthChecks:     healthChecks,
+		labelsErr:        labelsErr,
+		registerRetry:    defaultRegisterRetry,
+		lifecycle:        newLifecycle(),
+		pipeline:         newPipeline(),
 	}
 }
 
-func NewDefaultPack(packDef PackDef) Pack {
+// Creates a Pack using the configuration from the environment and the FLYTE_CONFIG config file, if set.
+// The configured labels are merged with the pack definition labels using PackDef.LabelMergeStrategy.
+func NewDefaultPack(packDef PackDef) ShutdownPack {
 	cfg := config.FromEnvironment()
-	return NewPack(packDef, client.NewClient(cfg.FlyteApiUrl, cfg.Timeout))
+	return newConfiguredPack(packDef, cfg, cfg.PollInterval)
 }
 
-func NewPackWithPolling(packDef PackDef, polling time.Duration) Pack {
-	cfg := config.FromEnvironment()
-	if polling < 500 * time.Millisecond {
+// Creates a Pack in the same way as NewDefaultPack, but with a custom commands polling frequency.
+func NewPackWithPolling(packDef PackDef, polling time.Duration) ShutdownPack {
+	return newConfiguredPack(packDef, config.FromEnvironment(), polling)
+}
+
+func newConfiguredPack(packDef PackDef, cfg config.Values, polling time.Duration) ShutdownPack {
+	logger := logging.OrDefault(packDef.Logger).With(logging.KeyPack, packDef.Name)
+	logger.Info(fmt.Sprintf("flyte configuration: %s", cfg.Redacted()))
+	labels, labelsErr := effectiveLabels(packDef, cfg.Labels, logger)
+	packDef.Labels = labels
+	if packDef.Redactor == nil {
+		packDef.Redactor = cfg.Redaction
+	}
+	if polling < 500*time.Millisecond {
 		polling = 500 * time.Millisecond
-		log.Warn().Msgf("Enforcing lower limit of 500 Milliseconds for commands polling frequency")
+		logger.Warn("Enforcing lower limit of 500 Milliseconds for commands polling frequency")
 	}
 	return pack{
-		PackDef: packDef,
-		client:  client.NewClient(cfg.FlyteApiUrl, cfg.Timeout),
+		PackDef:          packDef,
+		client:           newConfiguredClient(cfg, packDef),
 		pollingFrequency: polling,
-		healthChecks:     addDefaultHealthCheckIfNoneExist([]healthcheck.HealthCheck{}),
+		maxConcurrency:   cfg.MaxConcurrency,
+		healthAddr:       cfg.HealthAddr,
+		healthOptions:    healthServerOptions(cfg),
+		labelsErr:        labelsErr,
+		registerRetry:    registerRetryPolicy(cfg),
+		lifecycle:        newLifecycle(),
+		pipeline:         newPipeline(),
 	}
 }
 
-func addDefaultHealthCheckIfNoneExist(healthChecks []healthcheck.HealthCheck) []healthcheck.HealthCheck {
-	if len(healthChecks) == 0 {
-		healthChecks = append(healthChecks, func() (name string, health healthcheck.Health) {
-			return "DefaultCheck", healthcheck.Health{Healthy: true, Status: "Pack is running."}
-		})
+// creates the client from the configuration, recording to the pack metrics and logger. Without a configured proxy the
+// proxy environment variables are used. The TLS settings have already been validated when loading the configuration
+func newConfiguredClient(cfg config.Values, packDef PackDef) client.Client {
+	opts := []client.Option{
+		client.WithMetrics(packDef.Metrics),
+		client.WithLogger(packDef.Logger),
+		client.WithRedactor(packDef.Redactor),
+		client.WithTransport(client.Transport{
+			DialTimeout:         cfg.Transport.DialTimeout,
+			KeepAlive:           cfg.Transport.KeepAlive,
+			MaxIdleConnsPerHost: cfg.Transport.MaxIdleConnsPerHost,
+			TLSHandshakeTimeout: cfg.Transport.TLSHandshakeTimeout,
+			DisableHTTP2:        !cfg.Transport.HTTP2,
+		}),
+		client.WithCircuitBreaker(client.CircuitBreaker{
+			FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+			OpenInterval:     cfg.CircuitBreaker.OpenInterval,
+			HalfOpenProbes:   cfg.CircuitBreaker.HalfOpenProbes,
+		}),
+		client.WithEventEncoding(client.EventEncoding(cfg.EventEncoding)),
+		client.WithCompression(client.Compression{Threshold: cfg.Compression}),
+		client.WithMaxResponseSize(int64(cfg.MaxResponse)),
+		client.WithLinkTTL(cfg.LinkTTL),
+		// the JWT is taken from the configuration, not read from the environment again, even when it is not set
+		client.WithJWT(cfg.JWT),
+		client.WithRetryPolicy(client.RetryPolicy{
+			MaxAttempts:    cfg.Retry.MaxAttempts,
+			InitialBackoff: cfg.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Retry.MaxBackoff,
+			Jitter:         retryJitter,
+		}),
+	}
+	if cfg.Proxy.URL != nil {
+		opts = append(opts, client.WithProxy(cfg.Proxy.URL, cfg.Proxy.NoProxy...))
+	}
+	if len(cfg.Failover.URLs) > 0 {
+		opts = append(opts, client.WithFailover(client.Failover{
+			Endpoints:        cfg.Failover.URLs,
+			FailureThreshold: cfg.Failover.FailureThreshold,
+			FailbackInterval: cfg.Failover.FailbackInterval,
+		}))
 	}
-	return healthChecks
+	if tlsConfig, err := cfg.TLSConfig(); err == nil && tlsConfig != nil {
+		opts = append(opts, client.WithTLSConfig(tlsConfig))
+	}
+	if cfg.Insecure {
+		return client.NewInsecureClient(cfg.FlyteApiUrl, cfg.Timeout, opts...)
+	}
+	return client.NewClient(cfg.FlyteApiUrl, cfg.Timeout, opts...)
+}
+
+// merges the environment labels into the pack definition labels. Conflicting labels are logged and only the pack
+// definition labels are used. Invalid labels are logged but still used, as packs registered them before they were
+// validated. Either way the error is returned, so it can be reported on the health endpoint
+func effectiveLabels(packDef PackDef, envLabels map[string]string, logger logging.Logger) (map[string]string, error) {
+	labels, err := mergeLabels(packDef.Labels, envLabels, packDef.LabelMergeStrategy)
+	if err != nil {
+		logger.Error(fmt.Sprintf("cannot merge the environment labels of %s pack, using the pack definition labels", packDef.Name), logging.KeyError, err)
+		labels = packDef.Labels
+	} else if err = validateLabels(labels); err != nil {
+		logger.Warn(fmt.Sprintf("%s pack labels are not valid", packDef.Name), logging.KeyError, err)
+	}
+	logger.Info(fmt.Sprintf("%s pack labels: %v", packDef.Name, labels))
+	return labels, err
+}
+
+// the backoff between attempts to register a pack created with a client: the client retry policy defaults, 200ms
+// doubling up to 5 seconds
+var defaultRegisterRetry = client.RetryPolicy{Jitter: retryJitter}
+
+// the backoff between attempts to register the pack, from the configured retry policy
+func registerRetryPolicy(cfg config.Values) client.RetryPolicy {
+	return client.RetryPolicy{
+		InitialBackoff: cfg.Retry.InitialBackoff,
+		MaxBackoff:     cfg.Retry.MaxBackoff,
+		Jitter:         retryJitter,
+	}
+}
+
+func healthServerOptions(cfg config.Values) []healthcheck.ServerOption {
+	if cfg.HealthTLS.CertFile == "" && cfg.HealthTLS.KeyFile == "" {
+		return nil
+	}
+	return []healthcheck.ServerOption{healthcheck.WithTLS(cfg.HealthTLS.CertFile, cfg.HealthTLS.KeyFile)}
 }
 
 // Registers the pack with the flyte server and starts handling actions from the flyte server and invoking the necessary commands.
 // Once started the Pack is also available to send observed events.
-// This will also start up a pack health check server.
+// This will also start up a pack health check server first, which reports the pack is not ready until it has registered.
 func (p pack) Start() {
-	if err := p.register(); err != nil {
-		log.Err(err).Msg("cannot register pack")
-		time.Sleep(registerRetryWait)
-		p.Start()
-		return
+	p.startHealthCheckServer()
+	// the pack cannot do anything until it is registered, so it keeps trying, backing off up to the retry policy
+	// MaxBackoff. Each request is also retried as the client retry policy allows
+	for attempt := 1; ; attempt++ {
+		err := p.register()
+		if err == nil {
+			break
+		}
+		wait := p.registerRetry.Backoff(attempt)
+		p.log().Error(fmt.Sprintf("cannot register pack, retrying in %v", wait), logging.KeyError, err)
+		time.Sleep(wait)
 	}
-	log.Info().Msgf("%s pack has registered with flyte api", p.Name)
+	p.log().Info(fmt.Sprintf("%s pack has registered with flyte api", p.Name))
+	p.lifecycle.registered()
 	p.handleCommands()
-	p.startHealthCheckServer()
+}
+
+// Stops taking actions, waits for the actions in progress and stops the health check server.
+func (p pack) Shutdown(ctx context.Context) error {
+	p.log().Info(fmt.Sprintf("%s pack is shutting down", p.Name))
+	return p.lifecycle.shutdown(ctx)
 }
 
 // Spontaneously sends an event that the pack has observed to the flyte server.
 func (p pack) SendEvent(event Event) error {
-	return p.client.PostEvent(client.Event{
-		Name:    event.EventDef.Name,
-		Payload: event.Payload,
-	})
+	ctx, span := p.startSpan(context.Background(), tracing.SpanPostEvent)
+	defer span.End()
+	span.SetAttributes(tracing.AttributeEvent.String(event.EventDef.Name))
+
+	err := p.contextClient().PostEventContext(ctx, event.clientEvent())
+	tracing.RecordError(span, err)
+	if err == nil {
+		p.metrics().IncCounter(metrics.PackEventsSent, metrics.Labels{"event": event.EventDef.Name})
+	}
+	return err
+}
+
+// the logger of the pack, with the pack name field. The default logger if none is set
+func (p pack) log() logging.Logger {
+	return logging.OrDefault(p.Logger).With(logging.KeyPack, p.Name)
+}
+
+// describes a value such as an event for logging, with secrets masked
+func (p pack) redact(v interface{}) string {
+	return redact.OrDefault(p.Redactor).Redact(v)
+}
+
+// the metrics the pack records to. Nop if none are set, e.g. for a pack created without a constructor
+func (p pack) metrics() metrics.Metrics {
+	return metrics.OrNop(p.Metrics)
 }
 
 var StartHealthCheckServer = true // this is only overridden for testing purposes
 
 func (p pack) startHealthCheckServer() {
 	if StartHealthCheckServer == true {
-		healthcheck.Start(p.healthChecks)
+		opts := append([]healthcheck.ServerOption{healthcheck.WithLogger(p.log())}, p.healthOptions...)
+		s := healthcheck.NewServer(p.healthAddr, nil, opts...)
+		s.Register(healthcheck.Check{Kind: healthcheck.Liveness, Check: labelsHealthCheck(p.Labels, p.labelsErr)})
+		s.Register(p.lifecycle.healthChecks()...)
+		if p.pipeline != nil {
+			s.Register(p.pipeline.healthCheck(p.PipelineThresholds))
+		}
+		if r, ok := p.client.(client.CircuitStateReporter); ok {
+			s.Register(circuitHealthCheck(r))
+		}
+		if r, ok := p.client.(client.EndpointReporter); ok {
+			s.Register(endpointHealthCheck(r))
+		}
+		s.Register(p.healthChecks...)
+		// the metrics are served alongside the health checks if the backend can serve them, e.g. Prometheus
+		if h, ok := p.Metrics.(http.Handler); ok {
+			s.Handle("/metrics", h)
+		}
+		if err := s.Start(); err != nil {
+			p.log().Error(fmt.Sprintf("%s pack health checks are not available", p.Name), logging.KeyError, err)
+			return
+		}
+		p.lifecycle.setServer(s)
 	}
 }
 
 // The main configuration struct for defining a pack.
 type PackDef struct {
-	Name      string            // the pack name
-	Labels    map[string]string // the pack labels. These act as a filter that determines when the pack will execute against a flow
-	EventDefs []EventDef        // the event definitions of a pack. These can be events a pack observes and sends spontaneously
-	Commands  []Command         // the commands a pack exposes
-	HelpURL   *url.URL          // a help url to a page that describes what the pack does and how it is used
+	Name               string               // the pack name
+	Labels             map[string]string    // the pack labels. These act as a filter that determines when the pack will execute against a flow
+	LabelMergeStrategy LabelMergeStrategy   // how Labels are merged with FLYTE_LABELS by NewDefaultPack and NewPackWithPolling. Defaults to EnvironmentOverrides
+	EventDefs          []EventDef           // the event definitions of a pack. These can be events a pack observes and sends spontaneously
+	Commands           []Command            // the commands a pack exposes
+	HelpURL            *url.URL             // a help url to a page that describes what the pack does and how it is used
+	PipelineThresholds PipelineThresholds   // optional, when the built-in ActionPipeline health check reports the pack is down
+	Metrics            metrics.Metrics      // optional, where the pack records its metrics, e.g. metrics.NewPrometheus() served at /metrics on the health check server. No metrics are recorded if not set
+	TracerProvider     trace.TracerProvider // optional, the OpenTelemetry provider of the tracer the pack records spans for taking, handling and completing actions and sending events with. Defaults to the global provider
+	Logger             logging.Logger       // optional, where the pack, its client and health check server log. Defaults to logging.Default()
+	Redactor           redact.Redactor      // optional, how events are described in logs and errors. Defaults to redact.Default(), or the configured policy for NewDefaultPack and NewPackWithPolling
 }
 
-// Defines an event. The help URL is optional.
+// Defines an event. The help URL and payload schema are optional.
 type EventDef struct {
-	Name    string
-	HelpURL *url.URL
+	Name          string
+	HelpURL       *url.URL
+	PayloadSchema *Schema // optional, describes the JSON the event payload is marshalled into
 }
 
-// Defines a command - its name, the events it can output and a handler for incoming actions. The help URL is optional.
+// Defines a command - its name, the events it can output and a handler for incoming actions. The help URL and input schema are optional.
 type Command struct {
 	Name         string         // the name of the command
 	OutputEvents []EventDef     // the events a pack can output
 	Handler      CommandHandler // the handler is where the functionality of a pack is implemented when a command is called
 	HelpURL      *url.URL       // optional
+	InputSchema  *Schema        // optional, if set the action input is validated against it before the handler is invoked
+
+	ContextHandler ContextCommandHandler // optional, used instead of Handler when set
 }
 
 // Command handlers will be invoked with the input JSON when they are invoked from a flow step in the flyte server.
 type CommandHandler func(input json.RawMessage) Event
 
+// Context command handlers are invoked in the same way as command handlers, with a context holding the span of the
+// action. Use trace.SpanFromContext(ctx) to add attributes, or pass ctx on to start child spans.
+type ContextCommandHandler func(ctx context.Context, input json.RawMessage) Event
+
 // The event data the pack can send for events it observes (using SendEvent()) or from commands that have been called.
 // The payload will be marshalled into JSON, so should be annotated appropriately.
+// The other fields are optional, and sent in the event envelope alongside the payload.
 type Event struct {
 	EventDef EventDef
 	Payload  interface{}
+	// ID is sent as the Idempotency-Key so flyte-api only processes the event once. A new ID is generated if it is
+	// not set. Set it to keep the ID the same when the event is sent again, e.g. to the ID of the message an observed
+	// event came from
+	ID            string
+	OccurredAt    time.Time         // when the event happened, e.g. for replayed or batched events, sent as occurredAt. Defaults to when it is sent
+	CorrelationID string            // relates the event to others, e.g. the events of one deployment
+	Source        string            // what observed the event. Defaults to the pack name
+	Metadata      map[string]string // free-form details of the event, e.g. its schema version
+}
+
+// the event as it is sent to the flyte api
+func (e Event) clientEvent() client.Event {
+	return client.Event{
+		ID:            e.ID,
+		Name:          e.EventDef.Name,
+		Payload:       e.Payload,
+		OccurredAt:    e.OccurredAt,
+		CorrelationID: e.CorrelationID,
+		Source:        e.Source,
+		Metadata:      e.Metadata,
+	}
 }
 
 // This is the preferred way for packs to handle serious errors within the handler.
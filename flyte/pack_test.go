@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"github.com/ExpediaGroup/flyte-client/client"
 	"github.com/ExpediaGroup/flyte-client/config"
+	"github.com/ExpediaGroup/flyte-client/metrics"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -87,6 +89,7 @@ func Test_SendEvent(t *testing.T) {
 	e := Event{
 		EventDef: buildSucessEventDef,
 		Payload:  "blah blah",
+		ID:       "build-42",
 	}
 
 	c := MockClient{
@@ -100,6 +103,9 @@ func Test_SendEvent(t *testing.T) {
 			if event.Payload != e.Payload {
 				t.Fatalf("Expected event with payload %v, but received %v", e.Payload, event.Payload)
 			}
+			if event.ID != e.ID {
+				t.Fatalf("Expected event with id %q, but received %q", e.ID, event.ID)
+			}
 			return nil
 		},
 	}
@@ -212,6 +218,49 @@ func Test_EventAndCommandAggregationAndConversion(t *testing.T) {
 	p.Start()
 }
 
+func Test_Register_ShouldPublishInputAndPayloadSchemasAsLinks(t *testing.T) {
+	StartHealthCheckServer = false // we need this to stop multiple registrations of the healthcheck server
+
+	payloadSchema := &Schema{URL: createURL("http://jirapack/schemas/issue-created.json", t)}
+	issueCreatedEventDef := EventDef{Name: "IssueCreated", PayloadSchema: payloadSchema}
+	inputSchema := SchemaFor(struct {
+		Project string `json:"project"`
+	}{})
+
+	packDef := PackDef{
+		Name: "JiraPack",
+		Commands: []Command{{
+			Name:         "createIssue",
+			OutputEvents: []EventDef{issueCreatedEventDef},
+			InputSchema:  inputSchema,
+		}},
+		// defining the event again without the schema should not drop the schema link
+		EventDefs: []EventDef{{Name: "IssueCreated"}},
+	}
+
+	var registered client.Pack
+	c := MockClient{
+		createPack: func(p client.Pack) error {
+			registered = p
+			return nil
+		},
+	}
+
+	p := NewPack(packDef, c)
+	realPack := p.(pack)
+	require.NoError(t, realPack.register())
+
+	require.Len(t, registered.Commands, 1)
+	require.Len(t, registered.Commands[0].Links, 1)
+	assert.Equal(t, "inputSchema", registered.Commands[0].Links[0].Rel)
+	assert.Equal(t, "data", registered.Commands[0].Links[0].Href.Scheme)
+
+	require.Len(t, registered.EventDefs, 1)
+	require.Len(t, registered.EventDefs[0].Links, 1)
+	assert.Equal(t, "payloadSchema", registered.EventDefs[0].Links[0].Rel)
+	assert.Equal(t, "http://jirapack/schemas/issue-created.json", registered.EventDefs[0].Links[0].Href.String())
+}
+
 func Test_ShouldMoveOnToNextAction_IfErrorProcessingAction(t *testing.T) {
 	StartHealthCheckServer = false // we need this to stop multiple registrations of the healthcheck server
 
@@ -513,6 +562,247 @@ func Test_NewDefaultPackWithPolling_ShouldCreatePackWithDefaultClientAndCustomPo
 	assert.Equal(t, 1 * time.Second, realPack.pollingFrequency)
 }
 
+func Test_NewDefaultPack_ShouldApplyEnvironmentLabels(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		switch name {
+		case "FLYTE_API":
+			return server.URL
+		case "FLYTE_LABELS":
+			return "env=prod,region=eu"
+		}
+		return ""
+	}
+
+	p := NewDefaultPack(PackDef{
+		Name:   "JiraPack",
+		Labels: map[string]string{"env": "dev", "team": "flyte"},
+	})
+
+	realPack := p.(pack)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "eu", "team": "flyte"}, realPack.Labels)
+}
+
+func Test_NewPackWithPolling_ShouldApplyEnvironmentLabelsUsingMergeStrategy(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		switch name {
+		case "FLYTE_API":
+			return server.URL
+		case "FLYTE_LABELS":
+			return "env=prod,region=eu"
+		}
+		return ""
+	}
+
+	p := NewPackWithPolling(PackDef{
+		Name:               "JiraPack",
+		Labels:             map[string]string{"env": "dev"},
+		LabelMergeStrategy: CodeWins,
+	}, time.Second)
+
+	realPack := p.(pack)
+	assert.Equal(t, map[string]string{"env": "dev", "region": "eu"}, realPack.Labels)
+}
+
+func Test_NewPackWithPolling_ShouldUseThePackDefinitionLabelsWhenTheLabelsConflict(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		switch name {
+		case "FLYTE_API":
+			return server.URL
+		case "FLYTE_LABELS":
+			return "env=prod"
+		}
+		return ""
+	}
+
+	p := NewPackWithPolling(PackDef{
+		Name:               "JiraPack",
+		Labels:             map[string]string{"env": "dev"},
+		LabelMergeStrategy: ErrorOnConflict,
+	}, time.Second)
+
+	realPack := p.(pack)
+	assert.Equal(t, map[string]string{"env": "dev"}, realPack.Labels)
+	assert.EqualError(t, realPack.labelsErr, `conflicting pack labels: "env" (code: "dev", environment: "prod")`)
+}
+
+func Test_NewDefaultPack_ShouldKeepInvalidLabels(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		if name == "FLYTE_API" {
+			return server.URL
+		}
+		return ""
+	}
+
+	p := NewDefaultPack(PackDef{
+		Name:   "JiraPack",
+		Labels: map[string]string{"team": "flyte api"},
+	})
+
+	realPack := p.(pack)
+	assert.Equal(t, map[string]string{"team": "flyte api"}, realPack.Labels)
+	require.Error(t, realPack.labelsErr)
+	assert.Contains(t, realPack.labelsErr.Error(), `value "flyte api" of key "team"`)
+}
+
+func Test_NewPack_ShouldValidateLabels(t *testing.T) {
+	p := NewPack(PackDef{Name: "JiraPack", Labels: map[string]string{"-env": "dev"}}, MockClient{})
+
+	realPack := p.(pack)
+	assert.Equal(t, map[string]string{"-env": "dev"}, realPack.Labels)
+	require.Error(t, realPack.labelsErr)
+	assert.Contains(t, realPack.labelsErr.Error(), `key "-env" must start and end with an alphanumeric character`)
+}
+
+func Test_NewPack_ShouldAcceptValidLabels(t *testing.T) {
+	p := NewPack(PackDef{Name: "JiraPack", Labels: map[string]string{"env": "dev"}}, MockClient{})
+
+	assert.NoError(t, p.(pack).labelsErr)
+}
+
+func Test_NewConfiguredClient_ShouldOnlySendTheJWTFromTheConfiguration(t *testing.T) {
+	// given a server recording the authorization header
+	var authorization []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// and a FLYTE_JWT environment variable that is not part of the configuration
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		if name == config.FlyteJWTEnvName {
+			return "process.jwt.token"
+		}
+		return ""
+	}
+	cfg, err := config.Load(config.WithEnv(map[string]string{"FLYTE_API": server.URL}))
+	require.NoError(t, err)
+
+	// when
+	newConfiguredClient(cfg, PackDef{Name: "JiraPack"})
+
+	// then
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, authorization)
+	assert.Equal(t, "", authorization[0])
+}
+
+func Test_Start_ShouldBackOffBetweenRegistrationAttempts(t *testing.T) {
+	StartHealthCheckServer = false
+	// given flyte-api rejects the first two registrations
+	var attempts []time.Time
+	c := MockClient{
+		createPack: func(client.Pack) error {
+			attempts = append(attempts, time.Now())
+			if len(attempts) < 3 {
+				return errors.New("Failed to register pack with flyte service")
+			}
+			return nil
+		},
+		takeAction: func() (*client.Action, error) {
+			return nil, nil
+		},
+	}
+	p := NewPack(PackDef{Name: "JiraPack"}, c).(pack)
+	p.registerRetry = client.RetryPolicy{InitialBackoff: 10 * time.Millisecond}
+
+	// when
+	p.Start()
+
+	// then the wait doubles after each attempt
+	require.Len(t, attempts, 3)
+	assert.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), 10*time.Millisecond)
+	assert.GreaterOrEqual(t, attempts[2].Sub(attempts[1]), 20*time.Millisecond)
+}
+
+func Test_NewPackWithPolling_ShouldBackOffRegistrationAsTheRetryPolicy(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": []}`))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	prevGetEnv := config.GetEnv
+	defer func() { config.GetEnv = prevGetEnv }()
+	config.GetEnv = func(name string) string {
+		switch name {
+		case "FLYTE_API":
+			return server.URL
+		case "FLYTE_RETRY_INITIAL_BACKOFF":
+			return "1s"
+		case "FLYTE_RETRY_MAX_BACKOFF":
+			return "1m"
+		}
+		return ""
+	}
+
+	p := NewPackWithPolling(PackDef{Name: "JiraPack"}, time.Second)
+
+	assert.Equal(t, client.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Jitter: retryJitter}, p.(pack).registerRetry)
+}
+
+// an implementation of Pack outside the package, e.g. a mock, which does not implement Shutdown
+type startOnlyPack struct{}
+
+func (startOnlyPack) Start()                {}
+func (startOnlyPack) SendEvent(Event) error { return nil }
+
+func Test_Pack_ShouldNotRequireShutdown(t *testing.T) {
+	var p Pack = startOnlyPack{}
+	_, canShutdown := p.(ShutdownPack)
+	assert.False(t, canShutdown)
+
+	var created Pack = NewPack(PackDef{Name: "JiraPack"}, MockClient{})
+	_, canShutdown = created.(ShutdownPack)
+	assert.True(t, canShutdown)
+}
+
+func Test_NewPack_ShouldNotRecordMetricsByDefault(t *testing.T) {
+	p := NewPack(PackDef{Name: "JiraPack"}, MockClient{}).(pack)
+
+	assert.Nil(t, p.Metrics)
+	assert.Equal(t, metrics.Nop, p.metrics())
+}
+
 type createPack func(client.Pack) error
 type postEvent func(client.Event) error
 type takeAction func() (*client.Action, error)
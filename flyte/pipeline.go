@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"sync"
+	"time"
+)
+
+const (
+	pipelineCheckName                  = "ActionPipeline"
+	defaultTakeActionStaleAfter        = 5 * time.Minute
+	defaultCompletionStaleAfter        = 5 * time.Minute
+	defaultMaxConsecutivePipelineError = 10
+)
+
+// PipelineThresholds decide when the action pipeline health check reports the pack as down. Zero values use the defaults.
+type PipelineThresholds struct {
+	TakeActionStaleAfter time.Duration // down when no action has been taken successfully for this long while polling. Defaults to 5 minutes
+	CompletionStaleAfter time.Duration // down when actions have failed to complete for this long. Defaults to 5 minutes
+	MaxConsecutiveErrors int           // down when this many take or complete calls in a row have failed. Defaults to 10
+}
+
+func (t PipelineThresholds) withDefaults() PipelineThresholds {
+	if t.TakeActionStaleAfter <= 0 {
+		t.TakeActionStaleAfter = defaultTakeActionStaleAfter
+	}
+	if t.CompletionStaleAfter <= 0 {
+		t.CompletionStaleAfter = defaultCompletionStaleAfter
+	}
+	if t.MaxConsecutiveErrors <= 0 {
+		t.MaxConsecutiveErrors = defaultMaxConsecutivePipelineError
+	}
+	return t
+}
+
+// pipeline records the outcome of taking and completing actions, shared by every copy of a pack.
+// A nil pipeline is valid and records nothing.
+type pipeline struct {
+	mu                          sync.Mutex
+	polling                     bool // whether the pack takes actions, packs without commands never do
+	started                     time.Time
+	lastTake                    time.Time // the last successful TakeAction, whether or not an action was available
+	lastCompletion              time.Time
+	firstCompletionError        time.Time // when completions started failing, zero if the last one succeeded
+	consecutiveTakeErrors       int
+	consecutiveCompletionErrors int
+	inFlight                    int // actions being handled
+}
+
+func newPipeline() *pipeline {
+	return &pipeline{started: time.Now()}
+}
+
+func (p *pipeline) startPolling() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.polling = true
+	p.started = time.Now()
+}
+
+func (p *pipeline) tookAction(err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.consecutiveTakeErrors++
+		return
+	}
+	p.consecutiveTakeErrors = 0
+	p.lastTake = time.Now()
+}
+
+func (p *pipeline) completedAction(err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if p.consecutiveCompletionErrors == 0 {
+			p.firstCompletionError = time.Now()
+		}
+		p.consecutiveCompletionErrors++
+		return
+	}
+	p.consecutiveCompletionErrors = 0
+	p.firstCompletionError = time.Time{}
+	p.lastCompletion = time.Now()
+}
+
+func (p *pipeline) handling(delta int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight += delta
+}
+
+// PipelineStatus is reported as the status of the action pipeline health check.
+type PipelineStatus struct {
+	SinceLastTakeAction         string `json:"sinceLastTakeAction,omitempty"` // empty if no action has been taken successfully
+	SinceLastCompletion         string `json:"sinceLastCompletion,omitempty"` // empty if no action has been completed successfully
+	ConsecutiveTakeErrors       int    `json:"consecutiveTakeErrors"`
+	ConsecutiveCompletionErrors int    `json:"consecutiveCompletionErrors"`
+	InFlight                    int    `json:"inFlight"`
+	Reason                      string `json:"reason,omitempty"` // why the pipeline is degraded or down
+}
+
+// the health of the pipeline. It is down when a staleness or error threshold is crossed, and degraded when the
+// last take or complete call failed
+func (p *pipeline) health(t PipelineThresholds) healthcheck.Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	status := PipelineStatus{
+		SinceLastTakeAction:         since(now, p.lastTake),
+		SinceLastCompletion:         since(now, p.lastCompletion),
+		ConsecutiveTakeErrors:       p.consecutiveTakeErrors,
+		ConsecutiveCompletionErrors: p.consecutiveCompletionErrors,
+		InFlight:                    p.inFlight,
+	}
+
+	lastTake := p.lastTake
+	if lastTake.IsZero() {
+		lastTake = p.started
+	}
+	switch {
+	case p.polling && now.Sub(lastTake) > t.TakeActionStaleAfter:
+		status.Reason = fmt.Sprintf("no action taken successfully for more than %s", t.TakeActionStaleAfter)
+	case p.consecutiveCompletionErrors > 0 && now.Sub(p.firstCompletionError) > t.CompletionStaleAfter:
+		status.Reason = fmt.Sprintf("actions have failed to complete for more than %s", t.CompletionStaleAfter)
+	case p.consecutiveTakeErrors >= t.MaxConsecutiveErrors || p.consecutiveCompletionErrors >= t.MaxConsecutiveErrors:
+		status.Reason = fmt.Sprintf("%d or more consecutive errors", t.MaxConsecutiveErrors)
+	case p.consecutiveTakeErrors > 0 || p.consecutiveCompletionErrors > 0:
+		status.Reason = "the last call to flyte-api failed"
+		return healthcheck.Health{Healthy: true, State: healthcheck.StateDegraded, Status: status}
+	default:
+		return healthcheck.Health{Healthy: true, State: healthcheck.StateUp, Status: status}
+	}
+	return healthcheck.Health{Healthy: false, State: healthcheck.StateDown, Status: status}
+}
+
+// returns how long ago t was, or empty if t is not set
+func since(now, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return now.Sub(t).Round(time.Millisecond).String()
+}
+
+// the built-in readiness check reporting the health of the action pipeline
+func (p *pipeline) healthCheck(t PipelineThresholds) healthcheck.Check {
+	t = t.withDefaults()
+	return healthcheck.Check{
+		Kind: healthcheck.Readiness,
+		Name: pipelineCheckName,
+		Check: func() (name string, health healthcheck.Health) {
+			return pipelineCheckName, p.health(t)
+		},
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/healthcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var defaultThresholds = PipelineThresholds{}.withDefaults()
+
+func TestPipeline_ShouldBeUp_WhenTakingAndCompletingActions(t *testing.T) {
+	// given
+	p := newPipeline()
+	p.startPolling()
+
+	// when
+	p.tookAction(nil)
+	p.handling(1)
+	p.completedAction(nil)
+
+	// then
+	health := p.health(defaultThresholds)
+	assert.Equal(t, healthcheck.StateUp, health.State)
+	status := health.Status.(PipelineStatus)
+	assert.NotEmpty(t, status.SinceLastTakeAction)
+	assert.NotEmpty(t, status.SinceLastCompletion)
+	assert.Equal(t, 1, status.InFlight)
+}
+
+func TestPipeline_ShouldBeDegraded_WhenTheLastCallFailed(t *testing.T) {
+	// given
+	p := newPipeline()
+	p.tookAction(nil)
+
+	// when
+	p.completedAction(errors.New("rejected"))
+
+	// then
+	health := p.health(defaultThresholds)
+	assert.Equal(t, healthcheck.StateDegraded, health.State)
+	assert.Equal(t, 1, health.Status.(PipelineStatus).ConsecutiveCompletionErrors)
+
+	// and is up again once a call succeeds
+	p.completedAction(nil)
+	assert.Equal(t, healthcheck.StateUp, p.health(defaultThresholds).State)
+}
+
+func TestPipeline_ShouldBeDown_WhenTooManyConsecutiveErrors(t *testing.T) {
+	// given
+	p := newPipeline()
+
+	// when
+	for i := 0; i < 3; i++ {
+		p.tookAction(errors.New("flyte-api unavailable"))
+	}
+
+	// then
+	health := p.health(PipelineThresholds{MaxConsecutiveErrors: 3}.withDefaults())
+	assert.Equal(t, healthcheck.StateDown, health.State)
+	assert.Equal(t, "3 or more consecutive errors", health.Status.(PipelineStatus).Reason)
+}
+
+func TestPipeline_ShouldBeDown_WhenNoActionHasBeenTakenWithinThreshold(t *testing.T) {
+	// given a pack polling for actions
+	p := newPipeline()
+	p.startPolling()
+	p.tookAction(nil)
+
+	// when no action is taken successfully within the threshold
+	time.Sleep(20 * time.Millisecond)
+
+	// then
+	health := p.health(PipelineThresholds{TakeActionStaleAfter: 10 * time.Millisecond}.withDefaults())
+	assert.Equal(t, healthcheck.StateDown, health.State)
+	assert.Contains(t, health.Status.(PipelineStatus).Reason, "no action taken successfully")
+}
+
+func TestPipeline_ShouldNotBeStale_WhenThePackDoesNotPoll(t *testing.T) {
+	// given a pack without commands
+	p := newPipeline()
+
+	// when
+	time.Sleep(20 * time.Millisecond)
+
+	// then
+	health := p.health(PipelineThresholds{TakeActionStaleAfter: 10 * time.Millisecond}.withDefaults())
+	assert.Equal(t, healthcheck.StateUp, health.State)
+}
+
+func TestPipeline_ShouldBeDown_WhenActionsFailToCompleteForLongerThanThreshold(t *testing.T) {
+	// given
+	p := newPipeline()
+	p.completedAction(errors.New("rejected"))
+
+	// when
+	time.Sleep(20 * time.Millisecond)
+	p.completedAction(errors.New("rejected"))
+
+	// then
+	health := p.health(PipelineThresholds{CompletionStaleAfter: 10 * time.Millisecond}.withDefaults())
+	assert.Equal(t, healthcheck.StateDown, health.State)
+	assert.Contains(t, health.Status.(PipelineStatus).Reason, "failed to complete")
+}
+
+func TestPack_ShouldRecordActionPipeline(t *testing.T) {
+	StartHealthCheckServer = false // we need this to stop multiple registrations of the healthcheck server
+
+	// given
+	completed := make(chan bool)
+	command := Command{
+		Name: "RunBuild",
+		Handler: func(input json.RawMessage) Event {
+			return Event{EventDef: EventDef{Name: "BuildStarted"}}
+		},
+	}
+	taken := false
+	c := MockClient{
+		createPack: func(p client.Pack) error {
+			return nil
+		},
+		takeAction: func() (*client.Action, error) {
+			if !taken {
+				taken = true
+				return &client.Action{CommandName: command.Name}, nil
+			}
+			return nil, nil
+		},
+		completeAction: func(action client.Action, event client.Event) error {
+			defer func() { completed <- true }()
+			return errors.New("rejected")
+		},
+	}
+	p := NewPack(PackDef{Name: "BambooPack", Commands: []Command{command}}, c).(pack)
+	p.pollingFrequency = 10 * time.Millisecond
+
+	// when
+	p.Start()
+	require.NoError(t, waitForChannelOrTimeout(completed, time.Second))
+
+	// then
+	check := p.pipeline.healthCheck(p.PipelineThresholds)
+	require.Eventually(t, func() bool {
+		_, health := check.Check()
+		return health.State == healthcheck.StateDegraded
+	}, time.Second, 10*time.Millisecond)
+	_, health := check.Check()
+	status := health.Status.(PipelineStatus)
+	assert.Equal(t, 1, status.ConsecutiveCompletionErrors)
+	assert.Equal(t, 0, status.ConsecutiveTakeErrors)
+	assert.NotEmpty(t, status.SinceLastTakeAction)
+	assert.Equal(t, healthcheck.Readiness, check.Kind)
+}
@@ -0,0 +1,496 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	inputSchemaRel   = "inputSchema"
+	payloadSchemaRel = "payloadSchema"
+	schemaMediaType  = "application/schema+json"
+)
+
+// A JSON Schema document describing the shape of a command input or an event payload. Schemas are published with
+// the pack so flow writers know what JSON a command expects and what JSON an event carries.
+//
+// Input validation supports the commonly used subset of JSON Schema: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, allOf, anyOf and oneOf. Other keywords (e.g. $ref, format) are published but not enforced.
+// A hosted schema with no Document is published, but input is not validated against it.
+type Schema struct {
+	URL      *url.URL        // optional, where the schema is hosted. If not set the document is embedded in the pack links
+	Document json.RawMessage // the JSON Schema document
+}
+
+// Creates a schema from a JSON Schema document. An error is returned if the document is not a valid JSON object.
+func NewSchema(document []byte) (*Schema, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema document: %v", err)
+	}
+	return &Schema{Document: json.RawMessage(document)}, nil
+}
+
+// Creates a schema by reflecting on the Go type of the value passed in, honouring its json struct tags.
+// Fields without 'omitempty' that are not pointers are marked as required.
+func SchemaFor(v interface{}) *Schema {
+	doc := typeSchema(reflect.TypeOf(v), map[reflect.Type]bool{})
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+	b, _ := json.Marshal(doc)
+	return &Schema{Document: b}
+}
+
+// Validate checks the JSON passed in against the schema, returning an error describing every violation found. Any
+// JSON is valid when the schema has no Document, e.g. a schema only published by its URL.
+func (s *Schema) Validate(data json.RawMessage) error {
+	if len(bytes.TrimSpace(s.Document)) == 0 {
+		return nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(s.Document, &doc); err != nil {
+		return fmt.Errorf("invalid JSON schema document: %v", err)
+	}
+
+	var instance interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := d.Decode(&instance); err != nil {
+			return fmt.Errorf("input is not valid JSON: %v", err)
+		}
+	}
+
+	if violations := validate(doc, instance, "$"); len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// creates a link to the schema. If the schema is not hosted, the document is embedded as a data URI
+func (s *Schema) link(rel string) client.Link {
+	if s.URL != nil {
+		return createLink(s.URL, rel)
+	}
+	return createLink(&url.URL{
+		Scheme: "data",
+		Opaque: fmt.Sprintf("%s;base64,%s", schemaMediaType, base64.StdEncoding.EncodeToString(s.Document)),
+	}, rel)
+}
+
+// validates the instance against the schema returning a list of violations, each prefixed with the path of the failing value
+func validate(schema interface{}, instance interface{}, path string) []string {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return []string{fmt.Sprintf("%s: no value is allowed", path)}
+		}
+		return nil
+	case map[string]interface{}:
+		return validateObjectSchema(s, instance, path)
+	default:
+		return nil
+	}
+}
+
+func validateObjectSchema(s map[string]interface{}, instance interface{}, path string) []string {
+	if t, ok := s["type"]; ok && !matchesType(t, instance) {
+		return []string{fmt.Sprintf("%s: expected %s but got %s", path, describeType(t), jsonType(instance))}
+	}
+
+	var violations []string
+	if enum, ok := s["enum"].([]interface{}); ok && !containsValue(enum, instance) {
+		violations = append(violations, fmt.Sprintf("%s: value must be one of %s", path, toJSON(enum)))
+	}
+	if c, ok := s["const"]; ok && !equalValues(c, instance) {
+		violations = append(violations, fmt.Sprintf("%s: value must be %s", path, toJSON(c)))
+	}
+
+	switch v := instance.(type) {
+	case map[string]interface{}:
+		violations = append(violations, validateObject(s, v, path)...)
+	case []interface{}:
+		violations = append(violations, validateArray(s, v, path)...)
+	case string:
+		violations = append(violations, validateString(s, v, path)...)
+	case json.Number:
+		violations = append(violations, validateNumber(s, v, path)...)
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			violations = append(violations, validate(sub, instance, path)...)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok && countMatching(anyOf, instance, path) == 0 {
+		violations = append(violations, fmt.Sprintf("%s: value does not match any of the allowed schemas", path))
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok && countMatching(oneOf, instance, path) != 1 {
+		violations = append(violations, fmt.Sprintf("%s: value must match exactly one of the allowed schemas", path))
+	}
+	return violations
+}
+
+func validateObject(s map[string]interface{}, obj map[string]interface{}, path string) []string {
+	var violations []string
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					violations = append(violations, fmt.Sprintf("%s.%s: is required", path, name))
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	for _, name := range sortedKeys(obj) {
+		if propertySchema, ok := properties[name]; ok {
+			violations = append(violations, validate(propertySchema, obj[name], path+"."+name)...)
+			continue
+		}
+		if additional, ok := s["additionalProperties"]; ok {
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				violations = append(violations, fmt.Sprintf("%s.%s: is not an allowed property", path, name))
+				continue
+			}
+			violations = append(violations, validate(additional, obj[name], path+"."+name)...)
+		}
+	}
+	return violations
+}
+
+func validateArray(s map[string]interface{}, arr []interface{}, path string) []string {
+	var violations []string
+	if min, ok := number(s["minItems"]); ok && float64(len(arr)) < min {
+		violations = append(violations, fmt.Sprintf("%s: must have at least %v items", path, min))
+	}
+	if max, ok := number(s["maxItems"]); ok && float64(len(arr)) > max {
+		violations = append(violations, fmt.Sprintf("%s: must have at most %v items", path, max))
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range arr {
+			violations = append(violations, validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return violations
+}
+
+func validateString(s map[string]interface{}, str string, path string) []string {
+	var violations []string
+	length := float64(len([]rune(str)))
+	if min, ok := number(s["minLength"]); ok && length < min {
+		violations = append(violations, fmt.Sprintf("%s: must be at least %v characters long", path, min))
+	}
+	if max, ok := number(s["maxLength"]); ok && length > max {
+		violations = append(violations, fmt.Sprintf("%s: must be at most %v characters long", path, max))
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s: schema pattern %q is invalid: %v", path, pattern, err))
+		} else if !re.MatchString(str) {
+			violations = append(violations, fmt.Sprintf("%s: must match pattern %q", path, pattern))
+		}
+	}
+	return violations
+}
+
+// the compiled 'pattern' regexps of the schemas validated so far, by pattern
+var patterns sync.Map
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// compiles a 'pattern' regexp, once for each pattern
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if p, ok := patterns.Load(pattern); ok {
+		return p.(compiledPattern).re, p.(compiledPattern).err
+	}
+	re, err := regexp.Compile(pattern)
+	patterns.Store(pattern, compiledPattern{re, err})
+	return re, err
+}
+
+func validateNumber(s map[string]interface{}, n json.Number, path string) []string {
+	value, err := n.Float64()
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %q is not a valid number", path, n)}
+	}
+
+	var violations []string
+	if min, ok := number(s["minimum"]); ok && value < min {
+		violations = append(violations, fmt.Sprintf("%s: must be greater than or equal to %v", path, min))
+	}
+	if max, ok := number(s["maximum"]); ok && value > max {
+		violations = append(violations, fmt.Sprintf("%s: must be less than or equal to %v", path, max))
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && value <= min {
+		violations = append(violations, fmt.Sprintf("%s: must be greater than %v", path, min))
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && value >= max {
+		violations = append(violations, fmt.Sprintf("%s: must be less than %v", path, max))
+	}
+	return violations
+}
+
+func countMatching(schemas []interface{}, instance interface{}, path string) int {
+	matching := 0
+	for _, sub := range schemas {
+		if len(validate(sub, instance, path)) == 0 {
+			matching++
+		}
+	}
+	return matching
+}
+
+// checks the instance against the 'type' keyword, which can either be a single type name or a list of type names
+func matchesType(t interface{}, instance interface{}) bool {
+	switch types := t.(type) {
+	case string:
+		return isType(types, instance)
+	case []interface{}:
+		for _, name := range types {
+			if n, ok := name.(string); ok && isType(n, instance) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func isType(name string, instance interface{}) bool {
+	actual := jsonType(instance)
+	if name == "number" && actual == "integer" {
+		return true
+	}
+	return name == actual
+}
+
+func jsonType(instance interface{}) string {
+	switch v := instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func describeType(t interface{}) string {
+	if types, ok := t.([]interface{}); ok {
+		names := make([]string, len(types))
+		for i, name := range types {
+			names[i] = fmt.Sprintf("%v", name)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprintf("%v", t)
+}
+
+func containsValue(values []interface{}, instance interface{}) bool {
+	for _, v := range values {
+		if equalValues(v, instance) {
+			return true
+		}
+	}
+	return false
+}
+
+// compares a value from the schema with a value from the instance, normalising numbers so 1 and 1.0 are equal
+func equalValues(a, b interface{}) bool {
+	if an, ok := number(a); ok {
+		bn, ok := number(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	urlType        = reflect.TypeOf(url.URL{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// creates the JSON Schema for a Go type. 'seen' guards against recursive types, which are described as any value
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case urlType:
+		return map[string]interface{}{"type": "string", "format": "uri"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is marshalled as a base64 string
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Array:
+		// unlike []byte, a [N]byte array is marshalled as an array of numbers
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), seen), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	addStructFields(t, seen, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// adds the fields of the struct to the properties, flattening embedded structs the same way encoding/json does
+func addStructFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, seen, properties, required)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fieldSchema := typeSchema(f.Type, seen)
+		switch f.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			// nil values are marshalled as null, including a nil []byte
+			if t, ok := fieldSchema["type"].(string); ok {
+				fieldSchema["type"] = []string{t, "null"}
+			}
+		}
+		properties[name] = fieldSchema
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func parseTag(tag string) (name string, opts string) {
+	if i := strings.Index(tag, ","); i != -1 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
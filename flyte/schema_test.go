@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var issueSchema = `{
+	"type": "object",
+	"properties": {
+		"project": {"type": "string", "minLength": 2},
+		"priority": {"type": "integer", "minimum": 1, "maximum": 5},
+		"labels": {"type": "array", "items": {"type": "string"}},
+		"kind": {"enum": ["bug", "task"]}
+	},
+	"required": ["project"],
+	"additionalProperties": false
+}`
+
+func TestNewSchema_ShouldReturnErrorForInvalidDocument(t *testing.T) {
+	_, err := NewSchema([]byte(`not json`))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid JSON schema document")
+}
+
+func TestSchemaValidate_ShouldAcceptMatchingInput(t *testing.T) {
+	s, err := NewSchema([]byte(issueSchema))
+	require.NoError(t, err)
+
+	err = s.Validate(json.RawMessage(`{"project": "FOO", "priority": 3, "labels": ["a", "b"], "kind": "bug"}`))
+
+	assert.NoError(t, err)
+}
+
+func TestSchemaValidate_ShouldDescribeEveryViolation(t *testing.T) {
+	s, err := NewSchema([]byte(issueSchema))
+	require.NoError(t, err)
+
+	err = s.Validate(json.RawMessage(`{"priority": 1.5, "labels": ["a", 2], "kind": "epic", "extra": true}`))
+
+	require.Error(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"$.project: is required",
+		"$.extra: is not an allowed property",
+		"$.kind: value must be one of [\"bug\",\"task\"]",
+		"$.labels[1]: expected string but got integer",
+		"$.priority: expected integer but got number",
+	}, "; "), err.Error())
+}
+
+func TestSchemaValidate_ShouldRejectMissingInput(t *testing.T) {
+	s, err := NewSchema([]byte(issueSchema))
+	require.NoError(t, err)
+
+	err = s.Validate(nil)
+
+	assert.EqualError(t, err, "$: expected object but got null")
+}
+
+func TestSchemaValidate_ShouldRejectInvalidJSON(t *testing.T) {
+	s, err := NewSchema([]byte(issueSchema))
+	require.NoError(t, err)
+
+	err = s.Validate(json.RawMessage(`{"project":`))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "input is not valid JSON")
+}
+
+func TestSchemaValidate_ShouldSupportCombinators(t *testing.T) {
+	s, err := NewSchema([]byte(`{"oneOf": [{"type": "string"}, {"type": "integer", "exclusiveMinimum": 0}]}`))
+	require.NoError(t, err)
+
+	assert.NoError(t, s.Validate(json.RawMessage(`"abc"`)))
+	assert.NoError(t, s.Validate(json.RawMessage(`1`)))
+	assert.EqualError(t, s.Validate(json.RawMessage(`0`)), "$: value must match exactly one of the allowed schemas")
+}
+
+type schemaTestInput struct {
+	Project  string          `json:"project"`
+	Priority int             `json:"priority,omitempty"`
+	Due      *time.Time      `json:"due"`
+	Location url.URL         `json:"location"`
+	Tags     []string        `json:"tags,omitempty"`
+	Extra    map[string]bool `json:"extra,omitempty"`
+	Ignored  string          `json:"-"`
+	internal string
+	schemaTestEmbedded
+	Children []schemaTestInput `json:"children,omitempty"`
+}
+
+type schemaTestEmbedded struct {
+	Owner string `json:"owner"`
+}
+
+func TestSchemaFor_ShouldDeriveSchemaFromGoType(t *testing.T) {
+	s := SchemaFor(schemaTestInput{})
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(s.Document, &doc))
+
+	assert.Equal(t, "object", doc["type"])
+	assert.Equal(t, []interface{}{"location", "owner", "project"}, doc["required"])
+
+	properties := doc["properties"].(map[string]interface{})
+	assert.Len(t, properties, 8)
+	assert.Equal(t, map[string]interface{}{"type": "string"}, properties["project"])
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, properties["priority"])
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"string", "null"}, "format": "date-time"}, properties["due"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "uri"}, properties["location"])
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"array", "null"}, "items": map[string]interface{}{"type": "string"}}, properties["tags"])
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"object", "null"}, "additionalProperties": map[string]interface{}{"type": "boolean"}}, properties["extra"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, properties["owner"])
+	// recursive types are described as any value
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"array", "null"}, "items": map[string]interface{}{}}, properties["children"])
+}
+
+func TestSchemaFor_ShouldValidateValuesOfTheType(t *testing.T) {
+	s := SchemaFor(schemaTestInput{})
+
+	assert.NoError(t, s.Validate(json.RawMessage(`{"project": "FOO", "location": "http://jira", "owner": "bob", "due": null}`)))
+	assert.EqualError(t, s.Validate(json.RawMessage(`{"project": 1, "location": "http://jira", "owner": "bob"}`)), "$.project: expected string but got integer")
+}
+
+func TestSchemaFor_ShouldDescribeByteArraysAsArrays(t *testing.T) {
+	type checksums struct {
+		Raw    []byte   `json:"raw"`
+		SHA256 [32]byte `json:"sha256"`
+	}
+	s := SchemaFor(checksums{})
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(s.Document, &doc))
+	properties := doc["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"string", "null"}}, properties["raw"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}, "minItems": float64(32), "maxItems": float64(32)}, properties["sha256"])
+
+	value, err := json.Marshal(checksums{Raw: []byte("a"), SHA256: [32]byte{1}})
+	require.NoError(t, err)
+	assert.NoError(t, s.Validate(value))
+}
+
+func TestSchemaFor_ShouldAcceptNullForNilByteSlices(t *testing.T) {
+	type upload struct {
+		Content []byte `json:"content"`
+	}
+	s := SchemaFor(upload{})
+
+	value, err := json.Marshal(upload{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"content": null}`, string(value))
+	assert.NoError(t, s.Validate(value))
+	assert.NoError(t, s.Validate(json.RawMessage(`{"content": "YQ=="}`)))
+	assert.EqualError(t, s.Validate(json.RawMessage(`{"content": 1}`)), "$.content: expected string or null but got integer")
+}
+
+func TestSchemaValidate_ShouldAcceptAnyInput_WhenSchemaIsOnlyHosted(t *testing.T) {
+	s := &Schema{URL: createURL("http://jirapack/schemas/issue.json", t)}
+
+	assert.NoError(t, s.Validate(json.RawMessage(`{"project": 1}`)))
+}
+
+func TestSchemaValidate_ShouldReportAnInvalidPattern(t *testing.T) {
+	s, err := NewSchema([]byte(`{"type": "string", "pattern": "["}`))
+	require.NoError(t, err)
+
+	assert.Contains(t, s.Validate(json.RawMessage(`"FOO"`)).Error(), `$: schema pattern "[" is invalid`)
+	assert.Contains(t, s.Validate(json.RawMessage(`"FOO"`)).Error(), `$: schema pattern "[" is invalid`)
+}
+
+func TestSchemaLink_ShouldEmbedDocumentWhenSchemaIsNotHosted(t *testing.T) {
+	s, err := NewSchema([]byte(`{"type": "string"}`))
+	require.NoError(t, err)
+
+	link := s.link(inputSchemaRel)
+
+	assert.Equal(t, inputSchemaRel, link.Rel)
+	assert.Equal(t, "data:application/schema+json;base64,"+base64.StdEncoding.EncodeToString([]byte(`{"type": "string"}`)), link.Href.String())
+}
+
+func TestSchemaLink_ShouldUseSchemaURLWhenSchemaIsHosted(t *testing.T) {
+	s := &Schema{URL: createURL("http://jirapack/schemas/issue.json", t)}
+
+	link := s.link(payloadSchemaRel)
+
+	assert.Equal(t, payloadSchemaRel, link.Rel)
+	assert.Equal(t, "http://jirapack/schemas/issue.json", link.Href.String())
+}
@@ -0,0 +1,54 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"context"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// starts a span with the pack name attribute, from the tracer provider of the pack or the global one if none is set
+func (p pack) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Tracer(p.TracerProvider).Start(ctx, name, trace.WithAttributes(tracing.AttributePack.String(p.Name)))
+}
+
+// the client, sending the trace context with its requests when it supports it
+func (p pack) contextClient() client.ContextClient {
+	if c, ok := p.client.(client.ContextClient); ok {
+		return c
+	}
+	return withoutContext{p.client}
+}
+
+// calls a client that does not take a context, e.g. a mock, ignoring the context
+type withoutContext struct {
+	client.Client
+}
+
+func (c withoutContext) PostEventContext(_ context.Context, event client.Event) error {
+	return c.PostEvent(event)
+}
+
+func (c withoutContext) TakeActionContext(_ context.Context) (*client.Action, error) {
+	return c.TakeAction()
+}
+
+func (c withoutContext) CompleteActionContext(_ context.Context, action client.Action, event client.Event) error {
+	return c.CompleteAction(action, event)
+}
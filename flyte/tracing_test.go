@@ -0,0 +1,199 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flyte
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ExpediaGroup/flyte-client/client"
+	"github.com/ExpediaGroup/flyte-client/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+const flowTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestHandleAction_ShouldRecordSpansContinuingTheTraceOfTheAction(t *testing.T) {
+	// given a context aware handler
+	var handlerSpan trace.SpanContext
+	command := Command{
+		Name: "createIssue",
+		ContextHandler: func(ctx context.Context, input json.RawMessage) Event {
+			handlerSpan = trace.SpanContextFromContext(ctx)
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		},
+	}
+
+	// and a client that sends the trace context
+	var completedWith trace.SpanContext
+	mock := contextMockClient{completeAction: func(ctx context.Context, a client.Action, e client.Event) error {
+		completedWith = trace.SpanContextFromContext(ctx)
+		return nil
+	}}
+	exporter := tracetest.NewInMemoryExporter()
+	p := pack{PackDef: PackDef{Name: "JiraPack", TracerProvider: newTracerProvider(exporter)}, client: mock}
+
+	// when
+	a := &client.Action{CommandName: "createIssue", Metadata: map[string]string{"traceparent": flowTraceParent}}
+	p.handleAction(a, map[string]Command{command.Name: command})
+
+	// then
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	complete, handle := spans[0], spans[1]
+
+	assert.Equal(t, tracing.SpanHandleAction, handle.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", handle.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", handle.Parent.SpanID().String())
+	assert.True(t, handle.Parent.IsRemote())
+	assert.Contains(t, handle.Attributes, tracing.AttributePack.String("JiraPack"))
+	assert.Contains(t, handle.Attributes, tracing.AttributeCommand.String("createIssue"))
+	assert.Contains(t, handle.Attributes, tracing.AttributeEvent.String("IssueCreated"))
+	assert.Equal(t, handle.SpanContext, handlerSpan)
+
+	assert.Equal(t, tracing.SpanCompleteAction, complete.Name)
+	assert.Equal(t, handle.SpanContext.SpanID(), complete.Parent.SpanID())
+	assert.Contains(t, complete.Attributes, tracing.AttributeEvent.String("IssueCreated"))
+	assert.Equal(t, complete.SpanContext, completedWith)
+}
+
+func TestHandleAction_ShouldRecordPanicOnTheActionSpan(t *testing.T) {
+	// given
+	command := Command{
+		Name: "createIssue",
+		Handler: func(input json.RawMessage) Event {
+			panic("boom")
+		},
+	}
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error {
+		return errors.New("rejected")
+	}}
+	exporter := tracetest.NewInMemoryExporter()
+	p := pack{PackDef: PackDef{TracerProvider: newTracerProvider(exporter)}, client: mock}
+
+	// when
+	p.handleAction(&client.Action{CommandName: "createIssue"}, map[string]Command{command.Name: command})
+
+	// then
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "rejected", spans[0].Status.Description)
+	assert.Contains(t, spans[1].Attributes, tracing.AttributeEvent.String(fatalEventName))
+	assert.Equal(t, "command handler raised a panic: boom", spans[1].Status.Description)
+	assert.False(t, spans[1].Parent.IsValid())
+}
+
+func TestHandleAction_ShouldMakeSpansOfContextHandlersChildrenOfTheActionSpan(t *testing.T) {
+	// given a handler starting a child span with a tracer of the application
+	exporter := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(exporter)
+	command := Command{
+		Name: "createIssue",
+		ContextHandler: func(ctx context.Context, input json.RawMessage) Event {
+			_, span := tp.Tracer("jira").Start(ctx, "createJiraIssue")
+			span.End()
+			return Event{EventDef: EventDef{Name: "IssueCreated"}}
+		},
+	}
+	mock := completingMockClient{completeAction: func(a client.Action, e client.Event) error { return nil }}
+	p := pack{PackDef: PackDef{TracerProvider: tp}, client: mock}
+
+	// when
+	p.handleAction(&client.Action{CommandName: "createIssue"}, map[string]Command{command.Name: command})
+
+	// then the child span is part of the trace of the action
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	child, handle := spans[0], spans[2]
+	assert.Equal(t, "createJiraIssue", child.Name)
+	assert.Equal(t, handle.SpanContext.SpanID(), child.Parent.SpanID())
+}
+
+func TestTakeAction_ShouldRecordSpan(t *testing.T) {
+	// given
+	var takenWith trace.SpanContext
+	mock := contextMockClient{takeAction: func(ctx context.Context) (*client.Action, error) {
+		takenWith = trace.SpanContextFromContext(ctx)
+		return &client.Action{CommandName: "createIssue"}, nil
+	}}
+	exporter := tracetest.NewInMemoryExporter()
+	p := pack{PackDef: PackDef{TracerProvider: newTracerProvider(exporter)}, client: mock}
+
+	// when
+	_, err := p.takeAction()
+
+	// then
+	require.NoError(t, err)
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, tracing.SpanTakeAction, spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, tracing.AttributeCommand.String("createIssue"))
+	assert.Equal(t, spans[0].SpanContext, takenWith)
+}
+
+func TestSendEvent_ShouldRecordSpan(t *testing.T) {
+	// given
+	mock := contextMockClient{postEvent: func(ctx context.Context, e client.Event) error {
+		return errors.New("not accepted")
+	}}
+	exporter := tracetest.NewInMemoryExporter()
+	p := pack{PackDef: PackDef{TracerProvider: newTracerProvider(exporter)}, client: mock}
+
+	// when
+	err := p.SendEvent(Event{EventDef: EventDef{Name: "IssueClosed"}})
+
+	// then
+	assert.Error(t, err)
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, tracing.SpanPostEvent, spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, attribute.String("flyte.event", "IssueClosed"))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "not accepted", spans[0].Status.Description)
+}
+
+// a tracer provider exporting spans to the exporter as they end
+func newTracerProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+}
+
+type contextMockClient struct {
+	mockClient
+	postEvent      func(context.Context, client.Event) error
+	takeAction     func(context.Context) (*client.Action, error)
+	completeAction func(context.Context, client.Action, client.Event) error
+}
+
+func (m contextMockClient) PostEventContext(ctx context.Context, e client.Event) error {
+	return m.postEvent(ctx, e)
+}
+
+func (m contextMockClient) TakeActionContext(ctx context.Context) (*client.Action, error) {
+	return m.takeAction(ctx)
+}
+
+func (m contextMockClient) CompleteActionContext(ctx context.Context, a client.Action, e client.Event) error {
+	return m.completeAction(ctx, a, e)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// returned by freeDiskSpace on platforms where it cannot be read
+var errDiskSpaceUnsupported = errors.New("free disk space is not supported")
+
+// the most of a response body read by HTTPCheck when looking for the expected content
+const maxCheckedBodySize = 1 << 20
+
+// HTTPCheck checks a GET request to url returns the expected http status and, if bodyContains is not empty, a body
+// containing it. It is down if the request fails or times out, or the response is not as expected.
+func HTTPCheck(name, url string, expectedStatus int, bodyContains string, timeout time.Duration) HealthCheck {
+	httpClient := &http.Client{Timeout: timeout}
+	return func() (string, Health) {
+		r, err := httpClient.Get(url)
+		if err != nil {
+			return name, down("error in http call to %s: %v", url, err)
+		}
+		defer r.Body.Close()
+		if r.StatusCode != expectedStatus {
+			return name, down("unexpected http status from %s: got %d, want %d", url, r.StatusCode, expectedStatus)
+		}
+		if bodyContains != "" {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCheckedBodySize))
+			if err != nil {
+				return name, down("cannot read response from %s: %v", url, err)
+			}
+			if !strings.Contains(string(body), bodyContains) {
+				return name, down("response from %s does not contain %q", url, bodyContains)
+			}
+		}
+		return name, up("%s responded with http status %d", url, r.StatusCode)
+	}
+}
+
+// TCPCheck checks a TCP connection can be opened to addr, in the form 'host:port', within the timeout.
+func TCPCheck(name, addr string, timeout time.Duration) HealthCheck {
+	return func() (string, Health) {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return name, down("cannot connect to %s: %v", addr, err)
+		}
+		conn.Close()
+		return name, up("connected to %s", addr)
+	}
+}
+
+// DNSCheck checks host resolves to at least one address within the timeout.
+func DNSCheck(name, host string, timeout time.Duration) HealthCheck {
+	return func() (string, Health) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return name, down("cannot resolve %s: %v", host, err)
+		}
+		if len(addrs) == 0 {
+			return name, down("%s resolved to no addresses", host)
+		}
+		return name, up("%s resolved to %s", host, strings.Join(addrs, ", "))
+	}
+}
+
+// DiskSpaceCheck checks the free space on the file system containing path. It is degraded when fewer than
+// warnFreeBytes are free, and down when fewer than minFreeBytes are free. On platforms where the free space cannot be
+// read, i.e. other than linux, darwin, freebsd and windows, it is always degraded, reporting it is unsupported.
+func DiskSpaceCheck(name, path string, warnFreeBytes, minFreeBytes uint64) HealthCheck {
+	return func() (string, Health) {
+		free, err := freeDiskSpace(path)
+		if err == errDiskSpaceUnsupported {
+			return name, degraded("free disk space is not supported on %s", runtime.GOOS)
+		}
+		if err != nil {
+			return name, down("cannot get free disk space of %s: %v", path, err)
+		}
+		return name, threshold(free < minFreeBytes, free < warnFreeBytes, "%d bytes free on %s", free, path)
+	}
+}
+
+// GoroutineCheck checks the number of goroutines, which grows without limit when they leak. It is degraded when
+// there are more than warn goroutines, and down when there are more than max.
+func GoroutineCheck(name string, warn, max int) HealthCheck {
+	return func() (string, Health) {
+		n := runtime.NumGoroutine()
+		return name, threshold(n > max, n > warn, "%d goroutines", n)
+	}
+}
+
+// HeapCheck checks the bytes allocated on the heap. It is degraded when more than warnBytes are allocated, and down
+// when more than maxBytes are allocated. Reading the memory statistics briefly stops the world, so consider running
+// it on an interval rather than on each request.
+func HeapCheck(name string, warnBytes, maxBytes uint64) HealthCheck {
+	return func() (string, Health) {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return name, threshold(stats.HeapAlloc > maxBytes, stats.HeapAlloc > warnBytes, "%d heap bytes allocated", stats.HeapAlloc)
+	}
+}
+
+func up(format string, args ...interface{}) Health {
+	return Health{Healthy: true, State: StateUp, Status: fmt.Sprintf(format, args...)}
+}
+
+func degraded(format string, args ...interface{}) Health {
+	return Health{Healthy: true, State: StateDegraded, Status: fmt.Sprintf(format, args...)}
+}
+
+func down(format string, args ...interface{}) Health {
+	return Health{Healthy: false, State: StateDown, Status: fmt.Sprintf(format, args...)}
+}
+
+// returns down if the down threshold is crossed, degraded if the warning threshold is crossed, or up otherwise
+func threshold(isDown, isDegraded bool, format string, args ...interface{}) Health {
+	switch {
+	case isDown:
+		return down(format, args...)
+	case isDegraded:
+		return degraded(format, args...)
+	default:
+		return up(format, args...)
+	}
+}
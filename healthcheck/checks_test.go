@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHTTPCheck_shouldBeUp_whenStatusAndBodyAreAsExpected(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "UP"}`))
+	}))
+	defer server.Close()
+
+	// when
+	name, health := HTTPCheck("Api", server.URL, http.StatusOK, `"UP"`, time.Second)()
+
+	// then
+	assert.Equal(t, "Api", name)
+	assert.Equal(t, StateUp, health.State)
+	assert.True(t, health.Healthy)
+}
+
+func TestHTTPCheck_shouldBeDown_whenStatusIsUnexpected(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	// when
+	_, health := HTTPCheck("Api", server.URL, http.StatusOK, "", time.Second)()
+
+	// then
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, "got 502, want 200")
+}
+
+func TestHTTPCheck_shouldBeDown_whenBodyDoesNotContainExpectedContent(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "DOWN"}`))
+	}))
+	defer server.Close()
+
+	// when
+	_, health := HTTPCheck("Api", server.URL, http.StatusOK, `"UP"`, time.Second)()
+
+	// then
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, `does not contain "\"UP\""`)
+}
+
+func TestHTTPCheck_shouldBeDown_whenRequestTimesOut(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// when
+	_, health := HTTPCheck("Api", server.URL, http.StatusOK, "", 50*time.Millisecond)()
+
+	// then
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, "error in http call")
+}
+
+func TestTCPCheck_shouldBeUp_whenConnectionCanBeOpened(t *testing.T) {
+	// given
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	// when
+	name, health := TCPCheck("Database", l.Addr().String(), time.Second)()
+
+	// then
+	assert.Equal(t, "Database", name)
+	assert.Equal(t, StateUp, health.State)
+}
+
+func TestTCPCheck_shouldBeDown_whenNothingIsListening(t *testing.T) {
+	// given an address that is no longer listened on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	// when
+	_, health := TCPCheck("Database", addr, time.Second)()
+
+	// then
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, "cannot connect to "+addr)
+}
+
+func TestDNSCheck_shouldBeUp_whenHostResolves(t *testing.T) {
+	name, health := DNSCheck("Resolver", "localhost", time.Second)()
+
+	assert.Equal(t, "Resolver", name)
+	assert.Equal(t, StateUp, health.State)
+}
+
+func TestDNSCheck_shouldBeDown_whenHostDoesNotResolve(t *testing.T) {
+	_, health := DNSCheck("Resolver", "does-not-exist.invalid", time.Second)()
+
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, "cannot resolve does-not-exist.invalid")
+}
+
+func TestDiskSpaceCheck_shouldUseThresholds(t *testing.T) {
+	dir := os.TempDir()
+
+	_, health := DiskSpaceCheck("Disk", dir, 0, 0)()
+	assert.Equal(t, StateUp, health.State)
+
+	_, health = DiskSpaceCheck("Disk", dir, math.MaxUint64, 0)()
+	assert.Equal(t, StateDegraded, health.State)
+
+	_, health = DiskSpaceCheck("Disk", dir, math.MaxUint64, math.MaxUint64)()
+	assert.Equal(t, StateDown, health.State)
+}
+
+func TestDiskSpaceCheck_shouldBeDown_whenPathDoesNotExist(t *testing.T) {
+	_, health := DiskSpaceCheck("Disk", "/does/not/exist", 0, 0)()
+
+	assert.Equal(t, StateDown, health.State)
+	assert.Contains(t, health.Status, "cannot get free disk space of /does/not/exist")
+}
+
+func TestGoroutineCheck_shouldUseThresholds(t *testing.T) {
+	_, health := GoroutineCheck("Goroutines", math.MaxInt32, math.MaxInt32)()
+	assert.Equal(t, StateUp, health.State)
+
+	_, health = GoroutineCheck("Goroutines", 0, math.MaxInt32)()
+	assert.Equal(t, StateDegraded, health.State)
+
+	_, health = GoroutineCheck("Goroutines", 0, 0)()
+	assert.Equal(t, StateDown, health.State)
+}
+
+func TestHeapCheck_shouldUseThresholds(t *testing.T) {
+	_, health := HeapCheck("Heap", math.MaxUint64, math.MaxUint64)()
+	assert.Equal(t, StateUp, health.State)
+
+	_, health = HeapCheck("Heap", 0, math.MaxUint64)()
+	assert.Equal(t, StateDegraded, health.State)
+
+	_, health = HeapCheck("Heap", 0, 0)()
+	assert.Equal(t, StateDown, health.State)
+}
@@ -14,11 +14,28 @@
 
 /*
 Package healthcheck starts up a web server to handle pack healthchecks. On hitting the endpoint,
-the healthchecks are run and the response body will be populated with the results in JSON format with either a 200
-http header response code to indicate all checks have passed, or a 500 response code if one or more have failed.
+the healthchecks are run and the response body will be populated with the overall state and the result of each check
+in JSON format. Each check is up, degraded or down, and is critical unless registered with NonCritical. A 503 http
+header response code is returned if a critical check is down, otherwise a 200 is returned.
 On error such as a JSON marshalling error, a 500 response code will be returned and the error will be logged.
 If no healthchecks are passed in, the healthcheck server will always return a healthy response.
 
+Checks are served by kind on /livez, /readyz and /startupz, and the root endpoint runs every check. Checks passed to
+Start or NewServer are Readiness checks, use Server.Register for other kinds. A Gate is a check whose result is set by
+the application, e.g. to report readiness.
+
+Checks are run concurrently, and a check that does not return within its timeout is reported as unhealthy with a
+"timed out" status. It is not started again until it returns. A Check with an Interval runs in the background instead, and its last result is served with its age.
+
+Start serves the health checks on the default Port. To choose the address, serve over https or handle startup errors
+yourself, use NewServer:
+
+  s := healthcheck.NewServer("127.0.0.1:9000", healthChecks, healthcheck.WithTLS(certFile, keyFile))
+  if err := s.Start(); err != nil {
+	log.Fatal(err)
+  }
+  defer s.Shutdown(context.Background())
+
 
 Example
 
@@ -55,13 +72,24 @@ Example
 JSON Output
 
   {
-    "db": {
-      "healthy": true,
-      "status": "Good"
-    },
-    "jira": {
-      "healthy": true,
-      "status": "Ok"
+    "state": "up",
+    "checks": {
+      "db": {
+        "healthy": true,
+        "state": "up",
+        "status": "Good",
+        "critical": true,
+        "duration": "15µs",
+        "lastChanged": "2020-08-01T10:00:00Z"
+      },
+      "jira": {
+        "healthy": true,
+        "state": "up",
+        "status": "Ok",
+        "critical": true,
+        "duration": "8µs",
+        "lastChanged": "2020-08-01T10:00:00Z"
+      }
     }
   }
 
@@ -9,10 +9,22 @@ import (
 
 const timeout = time.Duration(5) * time.Second
 
+// FlyteApiHealthCheck checks flyte-api is up and responding to requests. It is degraded, but still healthy, if
+// flyte-api cannot be reached or responds with an unexpected http status, so the readiness probe of a pack does not
+// fail while flyte-api is unavailable. FlyteApiCheck reports an unreachable flyte-api as down.
 func FlyteApiHealthCheck(c client.Client) Health {
+	health := flyteApiHealth(c)
+	if health.State == StateDown {
+		health.Healthy, health.State = true, StateDegraded
+	}
+	return health
+}
+
+// checks flyte-api, which is down if it cannot be reached and degraded if it responds with an unexpected http status
+func flyteApiHealth(c client.Client) Health {
 	healthCheckURL, err := c.GetFlyteHealthCheckURL()
 	if err != nil {
-		return Health{Healthy: true, Status: fmt.Sprintf("cannot perform flyte-api healthcheck. error getting flyte-api healthcheck url. error: '%s'", err.Error())}
+		return Health{Healthy: false, State: StateDown, Status: fmt.Sprintf("cannot perform flyte-api healthcheck. error getting flyte-api healthcheck url. error: '%s'", err.Error())}
 	}
 
 	httpClient := &http.Client{
@@ -21,10 +33,24 @@ func FlyteApiHealthCheck(c client.Client) Health {
 
 	r, err := httpClient.Get(healthCheckURL.String())
 	if err != nil {
-		return Health{Healthy: true, Status: fmt.Sprintf("error in http call to flyte-api: '%s'. url: '%s'", err.Error(), healthCheckURL)}
+		return Health{Healthy: false, State: StateDown, Status: fmt.Sprintf("error in http call to flyte-api: '%s'. url: '%s'", err.Error(), healthCheckURL)}
 	}
+	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return Health{Healthy: true, Status: fmt.Sprintf("flyte-api is not responding as expected. http status: '%s'. url: '%s'", r.Status, healthCheckURL)}
+		return Health{Healthy: true, State: StateDegraded, Status: fmt.Sprintf("flyte-api is not responding as expected. http status: '%s'. url: '%s'", r.Status, healthCheckURL)}
+	}
+	return Health{Healthy: true, State: StateUp, Status: fmt.Sprintf("flyte-api is up and responding to requests. url: '%s'", healthCheckURL)}
+}
+
+// FlyteApiCheck registers a check of flyte-api as a non-critical readiness check named "FlyteApiCheck". When
+// flyte-api is unreachable the readiness endpoint reports it as down, for alerting, without failing the probe.
+func FlyteApiCheck(c client.Client) Check {
+	return Check{
+		Kind:        Readiness,
+		Name:        "FlyteApiCheck",
+		NonCritical: true,
+		Check: func() (name string, health Health) {
+			return "FlyteApiCheck", flyteApiHealth(c)
+		},
 	}
-	return Health{Healthy: true, Status: fmt.Sprintf("flyte-api is up and responding to requests. url: '%s'", healthCheckURL)}
 }
@@ -30,6 +30,7 @@ func Test_FlyteApiHealthCheck_ShouldReturnHealthyResponse(t *testing.T) {
 
 	// then
 	assert.Equal(t, true, health.Healthy)
+	assert.Equal(t, StateUp, health.State)
 	assert.Equal(t, "flyte-api is up and responding to requests. url: '"+flyteApiHealthCheckURL+"'", health.Status)
 }
 
@@ -44,6 +45,7 @@ func Test_FlyteApiHealthCheck_ShouldReturnErrorMessage_WhenErrorGettingHealthChe
 
 	// then
 	assert.Equal(t, true, health.Healthy)
+	assert.Equal(t, StateDegraded, health.State)
 	assert.Equal(t, "cannot perform flyte-api healthcheck. error getting flyte-api healthcheck url. error: 'flyte-api down!'", health.Status)
 }
 
@@ -66,6 +68,7 @@ func Test_FlyteApiHealthCheck_ShouldReturnErrorMessage_WhenHttpRequestToFlyteApi
 
 	// then
 	assert.Equal(t, true, health.Healthy)
+	assert.Equal(t, StateDegraded, health.State)
 	assert.Contains(t, health.Status, "error in http call to flyte-api:")
 	assert.Contains(t, health.Status, "url: '"+flyteApiHealthCheckURL+"'")
 }
@@ -89,9 +92,28 @@ func Test_FlyteApiHealthCheck_ShouldReturnErrorMessage_WhenHttpStatusReturnedFro
 
 	// then
 	assert.Equal(t, true, health.Healthy)
+	assert.Equal(t, StateDegraded, health.State)
 	assert.Equal(t, "flyte-api is not responding as expected. http status: '500 Internal Server Error'. url: '"+flyteApiHealthCheckURL+"'", health.Status)
 }
 
+func Test_FlyteApiCheck_ShouldBeANonCriticalReadinessCheck(t *testing.T) {
+	// given a mock client that cannot retrieve the healthcheck url
+	client := MockClient{
+		err: errors.New("flyte-api down!"),
+	}
+
+	// when
+	check := FlyteApiCheck(client)
+	name, health := check.Check()
+
+	// then
+	assert.Equal(t, Readiness, check.Kind)
+	assert.True(t, check.NonCritical)
+	assert.Equal(t, "FlyteApiCheck", name)
+	assert.Equal(t, StateDown, health.State)
+	assert.False(t, health.Healthy)
+}
+
 func createURL(u string) *url.URL {
 	url, _ := url.Parse(u)
 	return url
@@ -19,69 +19,112 @@ package healthcheck
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/rs/zerolog/log"
+	"github.com/ExpediaGroup/flyte-client/logging"
 	"net/http"
-	"time"
 )
 
 const Port = "8090"
 
 // Each healthcheck should populate and return this struct with the result of the healthcheck.
+// State is optional - if it is not set, a healthy check is StateUp and an unhealthy one is StateDown.
 type Health struct {
 	Healthy bool        `json:"healthy"`
-	Status  interface{} `json:"status"`
+	Status  interface{} `json:"status"` // details of the result, e.g. a message
+	State   State       `json:"state,omitempty"`
+}
+
+// State is the tri-state status of a health check, or of all the checks on an endpoint.
+type State string
+
+const (
+	StateUp       State = "up"
+	StateDegraded State = "degraded" // working, but with reduced functionality or performance
+	StateDown     State = "down"
+)
+
+// the state of the check, worked out from Healthy if State is not set
+func (h Health) state() State {
+	if h.State != "" {
+		return h.State
+	}
+	if h.Healthy {
+		return StateUp
+	}
+	return StateDown
 }
 
 // This is the function you implement for your healthcheck/s.
 type HealthCheck func() (name string, health Health)
 
-// Start will take the health checks you provide and start a web server to handle them.
+// Start will take the health checks you provide and start a web server on the default port to handle them.
+// Startup errors are only logged - use NewServer to handle them yourself.
 func Start(healthChecks []HealthCheck) *http.Server {
-	srv := &http.Server{Addr: fmt.Sprintf(":%s", Port)}
-	log.Info().Msgf("starting healthcheck server on port %s", Port)
-	http.HandleFunc("/", handler(healthChecks))
-	go func(s *http.Server) {
-		if err := s.ListenAndServe(); err != nil {
-			log.Err(err).Send()
-		}
-	}(srv)
-	time.Sleep(3 * time.Millisecond)
-	return srv
+	s := NewServer("", healthChecks)
+	if err := s.Start(); err != nil {
+		logging.Default().Error("cannot start healthcheck server", logging.KeyError, err)
+	}
+	return s.srv
 }
 
-// The handler will run the healthchecks passed in and output the results in JSON format, and will also write a 200
-// http header response code if all checks are successful. A 500 is returned if any checks fail or on error.
-// On error, no JSON will be returned but the error will be logged.
+// The handler will run the healthchecks passed in concurrently and output the overall state and the result of each
+// check in JSON format. Each check is reported with its state, status, duration and when its state last changed.
+// A check that does not return within DefaultTimeout is reported as down with a "timed out" status.
+//
+// The overall state is down if any critical check is down, degraded if any check is degraded or a non-critical check
+// is down, and up otherwise. A 503 http header response code is written when the overall state is down, and a 200
+// otherwise. On error, a 500 is returned with no JSON but the error will be logged.
 // If no healthchecks are registered, a successful header response will be returned.
 func handler(healthChecks []HealthCheck) func(w http.ResponseWriter, r *http.Request) {
+	runners := make([]*runner, len(healthChecks))
+	for i, hc := range healthChecks {
+		runners[i] = newRunner(Check{Check: hc}, i)
+	}
 	return func(w http.ResponseWriter, _ *http.Request) {
-		if len(healthChecks) == 0 {
-			log.Info().Msg("no healthchecks registered")
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+		serve(w, runners, logging.Default())
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+// the JSON response of a health check endpoint
+type response struct {
+	State  State             `json:"state"`
+	Checks map[string]result `json:"checks"`
+}
 
-		healthCheckResults := make(map[string]Health)
-		for _, healthCheck := range healthChecks {
-			name, health := healthCheck()
-			healthCheckResults[name] = health
-		}
+func serve(w http.ResponseWriter, runners []*runner, logger logging.Logger) {
+	if len(runners) == 0 {
+		logger.Info("no healthchecks registered")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		jsonResponse, err := json.Marshal(healthCheckResults)
-		if err != nil {
-			log.Err(err).Msgf("json marshalling error. healthCheckResults: %+v", healthCheckResults)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	healthCheckResults := runAll(runners)
+	resp := response{State: aggregate(healthCheckResults), Checks: healthCheckResults}
+
+	jsonResponse, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error(fmt.Sprintf("json marshalling error. healthCheckResults: %+v", healthCheckResults), logging.KeyError, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if resp.State == StateDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(jsonResponse)
+}
 
-		for _, hcResult := range healthCheckResults {
-			if !hcResult.Healthy {
-				w.WriteHeader(http.StatusInternalServerError)
-				break
-			}
+// works out the overall state from the results. Only a critical check that is down makes the overall state down
+func aggregate(results map[string]result) State {
+	state := StateUp
+	for _, r := range results {
+		switch {
+		case r.State == StateDown && r.Critical:
+			return StateDown
+		case r.State != StateUp:
+			state = StateDegraded
 		}
-		w.Write(jsonResponse)
 	}
+	return state
 }
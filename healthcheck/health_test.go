@@ -17,7 +17,9 @@ limitations under the License.
 package healthcheck
 
 import (
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -42,7 +44,10 @@ func TestHealthCheck_shouldReturn200AndValidJsonResponse_whenAllHealthChecksAreS
 	// then
 	assert.Equal(t, http.StatusOK, responseWriter.Code)
 	assert.Equal(t, "application/json; charset=utf-8", responseWriter.Header().Get("Content-Type"))
-	assert.Equal(t, `{"EndPointCheck":{"healthy":true,"status":"All good"},"OtherCheck":{"healthy":true,"status":"Ok"}}`, responseWriter.Body.String())
+	resp := decode(t, responseWriter.Body.String())
+	assert.Equal(t, StateUp, resp.State)
+	assertCheck(t, resp, "EndPointCheck", StateUp, "All good")
+	assertCheck(t, resp, "OtherCheck", StateUp, "Ok")
 }
 
 func TestHealthCheck_shouldReturn200AndLogMessage_whenNoHealthChecksAreRegistered(t *testing.T) {
@@ -59,7 +64,7 @@ func TestHealthCheck_shouldReturn200AndLogMessage_whenNoHealthChecksAreRegistere
 	assert.Equal(t, http.StatusOK, responseWriter.Code)
 }
 
-func TestHealthCheck_shouldReturn500AndValidJsonResponse_whenAHealthCheckFails(t *testing.T) {
+func TestHealthCheck_shouldReturn503AndValidJsonResponse_whenAHealthCheckFails(t *testing.T) {
 	// given these healthchecks - with one failing
 	endPointCheck := func() (name string, health Health) {
 		return "EndPointCheck", Health{Healthy: true, Status: "All good"}
@@ -76,9 +81,12 @@ func TestHealthCheck_shouldReturn500AndValidJsonResponse_whenAHealthCheckFails(t
 	handler(healthChecks)(responseWriter, request)
 
 	// then
-	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, responseWriter.Code)
 	assert.Equal(t, "application/json; charset=utf-8", responseWriter.Header().Get("Content-Type"))
-	assert.Equal(t, `{"EndPointCheck":{"healthy":true,"status":"All good"},"OtherCheck":{"healthy":false,"status":"Oh No!!"}}`, responseWriter.Body.String())
+	resp := decode(t, responseWriter.Body.String())
+	assert.Equal(t, StateDown, resp.State)
+	assertCheck(t, resp, "EndPointCheck", StateUp, "All good")
+	assertCheck(t, resp, "OtherCheck", StateDown, "Oh No!!")
 }
 
 func TestHealthCheck_shouldReturn500HeaderResponse_whenJsonMarshallingError(t *testing.T) {
@@ -98,3 +106,44 @@ func TestHealthCheck_shouldReturn500HeaderResponse_whenJsonMarshallingError(t *t
 	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
 	assert.Equal(t, "application/json; charset=utf-8", responseWriter.Header().Get("Content-Type"))
 }
+
+func TestHealthCheck_shouldReturn200AndDegradedState_whenAHealthCheckIsDegraded(t *testing.T) {
+	// given these healthchecks - with one degraded
+	endPointCheck := func() (name string, health Health) {
+		return "EndPointCheck", Health{Healthy: true, Status: "All good"}
+	}
+	slowCheck := func() (name string, health Health) {
+		return "SlowCheck", Health{Healthy: true, State: StateDegraded, Status: "responding slowly"}
+	}
+	healthChecks := []HealthCheck{endPointCheck, slowCheck}
+
+	request := httptest.NewRequest("GET", "/", nil)
+	responseWriter := httptest.NewRecorder()
+
+	// when the healthcheck on the pack is called
+	handler(healthChecks)(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	resp := decode(t, responseWriter.Body.String())
+	assert.Equal(t, StateDegraded, resp.State)
+	assertCheck(t, resp, "SlowCheck", StateDegraded, "responding slowly")
+}
+
+func decode(t *testing.T, body string) response {
+	var resp response
+	require.NoError(t, json.Unmarshal([]byte(body), &resp), body)
+	return resp
+}
+
+// asserts the check is in the response with the state and status passed in, and reports its duration and when its
+// state last changed
+func assertCheck(t *testing.T, resp response, name string, state State, status string) {
+	require.Contains(t, resp.Checks, name)
+	check := resp.Checks[name]
+	assert.Equal(t, state, check.State)
+	assert.Equal(t, state != StateDown, check.Healthy)
+	assert.Equal(t, status, check.Status)
+	assert.NotEmpty(t, check.Duration)
+	assert.False(t, check.LastChanged.IsZero())
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Kind decides which endpoint a health check is served on, matching the Kubernetes probe types.
+type Kind int
+
+const (
+	// served on /readyz. A failing readiness check stops traffic being sent to the pack, e.g. when a
+	// downstream dependency is unavailable. This is the default
+	Readiness Kind = iota
+	// served on /livez. A failing liveness check means the pack is broken and should be restarted
+	Liveness
+	// served on /startupz. A failing startup check means the pack has not finished starting
+	Startup
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Readiness:
+		return "readiness"
+	case Liveness:
+		return "liveness"
+	case Startup:
+		return "startup"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// the endpoint each kind of check is served on
+func (k Kind) path() string {
+	switch k {
+	case Liveness:
+		return "/livez"
+	case Startup:
+		return "/startupz"
+	default:
+		return "/readyz"
+	}
+}
+
+// Check is a health check registered with a kind. Checks are run concurrently, each with its own timeout.
+type Check struct {
+	Kind     Kind
+	Check    HealthCheck
+	Name     string        // optional, overrides the name returned by the check. Also used to report the check if it times out before it has ever returned
+	Timeout  time.Duration // optional, how long the check can run before it is reported as timed out. Defaults to DefaultTimeout
+	Interval time.Duration // optional, runs the check in the background on this interval and serves the cached result and its age, rather than running it on each request
+	// optional, a non-critical check that is down degrades the overall state of the endpoint rather than making it
+	// down, so it does not fail the probe. Use it for checks that are useful for alerting but should not restart or
+	// stop traffic to the pack
+	NonCritical bool
+}
+
+// Gate is a health check whose result is set by the application rather than worked out on each request,
+// e.g. to report the pack is not ready until it has registered with the flyte api.
+type Gate struct {
+	name   string
+	mu     sync.RWMutex
+	health Health
+}
+
+// NewGate creates a gate reported under name, with the initial health passed in.
+func NewGate(name string, health Health) *Gate {
+	return &Gate{name: name, health: health}
+}
+
+// Set changes the health reported by the gate.
+func (g *Gate) Set(healthy bool, status interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.health = Health{Healthy: healthy, Status: status}
+}
+
+// Check reports the current health of the gate. It is a HealthCheck, so can be registered as g.Check.
+func (g *Gate) Check() (name string, health Health) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.name, g.health
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a check can run before it is reported as timed out, if Check.Timeout is not set.
+const DefaultTimeout = 5 * time.Second
+
+const timedOutStatus = "timed out"
+
+// the JSON reported for each check. Age is only set for results cached by a check that runs on an interval
+type result struct {
+	Healthy     bool        `json:"healthy"`
+	State       State       `json:"state"`
+	Status      interface{} `json:"status"`
+	Critical    bool        `json:"critical"`
+	Duration    string      `json:"duration"`    // how long the check took to run
+	LastChanged time.Time   `json:"lastChanged"` // when the state of the check last changed, or it was first run
+	Age         string      `json:"age,omitempty"`
+}
+
+// runner runs a check with its timeout, tracks when its state changes and caches the last result of checks that
+// run in the background
+type runner struct {
+	check       Check
+	index       int // used to name a check that times out before it has ever returned its name
+	mu          sync.RWMutex
+	name        string
+	state       State
+	lastChanged time.Time
+	cached      *result
+	checkedAt   time.Time
+	running     *run // the run in progress, nil if there is none
+}
+
+// a run of the check. done is closed once the check returns
+type run struct {
+	done     chan struct{}
+	start    time.Time
+	name     string
+	health   Health
+	duration time.Duration
+}
+
+func newRunner(check Check, index int) *runner {
+	return &runner{check: check, index: index, name: check.Name}
+}
+
+func (r *runner) timeout() time.Duration {
+	if r.check.Timeout > 0 {
+		return r.check.Timeout
+	}
+	return DefaultTimeout
+}
+
+// runs the check, reporting it as down with a "timed out" status if it does not return within its timeout. A check
+// that times out carries on running in the background until it returns, and only one run is in progress at a time:
+// while a check is running it is not started again, the result of the run in progress is waited for instead, so a
+// check that hangs is reported as timed out rather than being started on every request
+func (r *runner) run() (string, result) {
+	current := r.start()
+	timer := time.NewTimer(r.timeout() - time.Since(current.start))
+	defer timer.Stop()
+
+	select {
+	case <-current.done:
+		return r.record(current.name, current.health, current.duration)
+	case <-timer.C:
+		return r.record("", Health{Healthy: false, Status: timedOutStatus}, time.Since(current.start))
+	}
+}
+
+// starts the check, unless it is already running, returning the run in progress
+func (r *runner) start() *run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running != nil {
+		return r.running
+	}
+
+	current := &run{done: make(chan struct{}), start: time.Now()}
+	r.running = current
+	go func() {
+		current.name, current.health = r.check.Check()
+		current.duration = time.Since(current.start)
+		r.mu.Lock()
+		r.running = nil
+		r.mu.Unlock()
+		close(current.done)
+	}()
+	return current
+}
+
+// records the outcome of a run, returning the name to report it under and the result
+func (r *runner) record(name string, health Health, duration time.Duration) (string, result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.check.Name == "" && name != "" {
+		r.name = name
+	}
+	if r.name == "" {
+		name = fmt.Sprintf("check-%d", r.index)
+	} else {
+		name = r.name
+	}
+
+	state := health.state()
+	if state != r.state || r.lastChanged.IsZero() {
+		r.state = state
+		r.lastChanged = time.Now()
+	}
+	return name, result{
+		Healthy:     state != StateDown,
+		State:       state,
+		Status:      health.Status,
+		Critical:    !r.check.NonCritical,
+		Duration:    duration.Round(time.Microsecond).String(),
+		LastChanged: r.lastChanged.UTC(),
+	}
+}
+
+// returns the cached result of a background check, or runs the check if it has no cached result
+func (r *runner) result() (string, result) {
+	r.mu.RLock()
+	if r.cached != nil {
+		defer r.mu.RUnlock()
+		cached := *r.cached
+		cached.Age = time.Since(r.checkedAt).Round(time.Millisecond).String()
+		return r.name, cached
+	}
+	r.mu.RUnlock()
+
+	return r.run()
+}
+
+// runs the check straight away and then on its interval, caching the results, until stop is closed
+func (r *runner) runEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		name, res := r.run()
+		r.mu.Lock()
+		r.name = name
+		r.cached = &res
+		r.checkedAt = time.Now()
+		r.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runs the checks concurrently, returning the results by check name
+func runAll(runners []*runner) map[string]result {
+	names := make([]string, len(runners))
+	results := make([]result, len(runners))
+	var wg sync.WaitGroup
+	for i, r := range runners {
+		wg.Add(1)
+		go func(i int, r *runner) {
+			defer wg.Done()
+			names[i], results[i] = r.result()
+		}(i, r)
+	}
+	wg.Wait()
+
+	byName := make(map[string]result, len(runners))
+	for i, name := range names {
+		byName[name] = results[i]
+	}
+	return byName
+}
@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func sleepingCheck(name string, d time.Duration) HealthCheck {
+	return func() (string, Health) {
+		time.Sleep(d)
+		return name, Health{Healthy: true, Status: "Ok"}
+	}
+}
+
+func TestRunAll_shouldRunChecksConcurrently(t *testing.T) {
+	// given
+	runners := []*runner{
+		newRunner(Check{Check: sleepingCheck("First", 200*time.Millisecond)}, 0),
+		newRunner(Check{Check: sleepingCheck("Second", 200*time.Millisecond)}, 1),
+		newRunner(Check{Check: sleepingCheck("Third", 200*time.Millisecond)}, 2),
+	}
+
+	// when
+	start := time.Now()
+	results := runAll(runners)
+
+	// then
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "checks should run in parallel, took %s", time.Since(start))
+	assert.Len(t, results, 3)
+}
+
+func TestRunAll_shouldReportTimedOutCheckAsUnhealthy(t *testing.T) {
+	// given
+	runners := []*runner{
+		newRunner(Check{Check: okCheck}, 0),
+		newRunner(Check{Check: sleepingCheck("Slow", time.Second), Name: "Slow", Timeout: 50 * time.Millisecond}, 1),
+		newRunner(Check{Check: sleepingCheck("Unnamed", time.Second), Timeout: 50 * time.Millisecond}, 2),
+	}
+
+	// when
+	start := time.Now()
+	results := runAll(runners)
+
+	// then
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "timed out checks should not be waited for")
+	assert.Equal(t, StateUp, results["Check"].State)
+	assert.Equal(t, "Ok", results["Check"].Status)
+	for _, name := range []string{"Slow", "check-2"} {
+		assert.Equal(t, StateDown, results[name].State)
+		assert.False(t, results[name].Healthy)
+		assert.Equal(t, "timed out", results[name].Status)
+	}
+}
+
+func TestRunner_shouldReportTimedOutCheckWithPreviousName(t *testing.T) {
+	// given a check that has returned its name before
+	slow := int32(0)
+	r := newRunner(Check{Timeout: 50 * time.Millisecond, Check: func() (string, Health) {
+		if atomic.LoadInt32(&slow) == 1 {
+			time.Sleep(time.Second)
+		}
+		return "Flaky", Health{Healthy: true}
+	}}, 0)
+	name, _ := r.run()
+	require.Equal(t, "Flaky", name)
+
+	// when it times out
+	atomic.StoreInt32(&slow, 1)
+	name, res := r.run()
+
+	// then
+	assert.Equal(t, "Flaky", name)
+	assert.Equal(t, StateDown, res.State)
+	assert.Equal(t, "timed out", res.Status)
+}
+
+func TestRunner_shouldNotStartACheckAgain_whileItIsRunning(t *testing.T) {
+	// given a check that hangs
+	started := int32(0)
+	release := make(chan struct{})
+	r := newRunner(Check{Name: "Hung", Timeout: 20 * time.Millisecond, Check: func() (string, Health) {
+		atomic.AddInt32(&started, 1)
+		<-release
+		return "Hung", Health{Healthy: true}
+	}}, 0)
+
+	// when it is run repeatedly
+	var results []result
+	for i := 0; i < 5; i++ {
+		_, res := r.run()
+		results = append(results, res)
+	}
+
+	// then it is only started once, and reported as timed out while it runs
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+	for _, res := range results {
+		assert.Equal(t, "timed out", res.Status)
+	}
+
+	// and it is started again once it has returned
+	close(release)
+	require.Eventually(t, func() bool {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.running == nil
+	}, time.Second, 5*time.Millisecond)
+	_, res := r.run()
+	assert.Equal(t, StateUp, res.State)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&started))
+}
+
+func TestServer_shouldServeCachedResultAndAge_forChecksRunOnAnInterval(t *testing.T) {
+	// given
+	var runs int32
+	check := func() (string, Health) {
+		atomic.AddInt32(&runs, 1)
+		return "Background", Health{Healthy: true, Status: "Ok"}
+	}
+	s := NewServer("127.0.0.1:0", nil)
+	s.Register(Check{Kind: Readiness, Check: check, Interval: time.Hour})
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 1 }, time.Second, 10*time.Millisecond)
+
+	// when
+	code, body := get(t, "http://"+s.Addr()+"/readyz")
+	get(t, "http://"+s.Addr()+"/readyz")
+
+	// then the check is not run on each request
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	var resp response
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+	assert.True(t, resp.Checks["Background"].Healthy)
+	age, err := time.ParseDuration(resp.Checks["Background"].Age)
+	require.NoError(t, err)
+	assert.True(t, age >= 0 && age < time.Second)
+}
+
+func TestRunner_shouldOnlyUpdateLastChanged_whenStateChanges(t *testing.T) {
+	// given
+	state := StateUp
+	r := newRunner(Check{Check: func() (string, Health) {
+		return "Changing", Health{State: state, Healthy: state != StateDown}
+	}}, 0)
+	_, first := r.run()
+	time.Sleep(10 * time.Millisecond)
+
+	// when the state stays the same
+	_, same := r.run()
+
+	// then
+	assert.Equal(t, first.LastChanged, same.LastChanged)
+
+	// when the state changes
+	state = StateDegraded
+	_, changed := r.run()
+
+	// then
+	assert.True(t, changed.LastChanged.After(first.LastChanged))
+	assert.Equal(t, StateDegraded, changed.State)
+	assert.True(t, changed.Healthy)
+}
+
+func TestAggregate_shouldOnlyBeDown_whenACriticalCheckIsDown(t *testing.T) {
+	up := result{State: StateUp, Critical: true}
+	degraded := result{State: StateDegraded, Critical: true}
+	criticalDown := result{State: StateDown, Critical: true}
+	nonCriticalDown := result{State: StateDown, Critical: false}
+
+	assert.Equal(t, StateUp, aggregate(map[string]result{"a": up, "b": up}))
+	assert.Equal(t, StateDegraded, aggregate(map[string]result{"a": up, "b": degraded}))
+	assert.Equal(t, StateDegraded, aggregate(map[string]result{"a": up, "b": nonCriticalDown}))
+	assert.Equal(t, StateDown, aggregate(map[string]result{"a": degraded, "b": criticalDown}))
+}
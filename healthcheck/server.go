@@ -0,0 +1,182 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/ExpediaGroup/flyte-client/logging"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Server serves the health checks on its own ServeMux, so it does not clash with other handlers registered on
+// http.DefaultServeMux and more than one can run in the same process.
+//
+// Each kind of check is served on its own endpoint: /livez, /readyz and /startupz. The root endpoint / runs every
+// check, as it did before the checks had kinds.
+type Server struct {
+	addr     string
+	certFile string
+	keyFile  string
+	mux      *http.ServeMux
+	srv      *http.Server
+	listener net.Listener
+	mu       sync.RWMutex
+	runners  []*runner
+	started  bool
+	stop     chan struct{} // closed on shutdown to stop the checks running in the background
+	stopOnce sync.Once
+	logger   logging.Logger
+}
+
+// ServerOption customises a Server created with NewServer.
+type ServerOption func(*Server)
+
+// WithTLS serves the health checks over https using the PEM encoded certificate and key files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithLogger logs to l instead of the default logger.
+func WithLogger(l logging.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// NewServer creates a health check server that will listen on addr, in the form 'host:port'. If addr is empty the
+// default Port is used on all interfaces. The health checks passed in are registered as Readiness checks, use
+// Register for other kinds. The server does not listen until Start is called.
+func NewServer(addr string, healthChecks []HealthCheck, opts ...ServerOption) *Server {
+	if addr == "" {
+		addr = fmt.Sprintf(":%s", Port)
+	}
+	s := &Server{addr: addr, mux: http.NewServeMux(), stop: make(chan struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, hc := range healthChecks {
+		s.Register(Check{Kind: Readiness, Check: hc})
+	}
+	s.mux.HandleFunc("/", s.handler(nil))
+	for _, kind := range []Kind{Liveness, Readiness, Startup} {
+		kind := kind
+		s.mux.HandleFunc(kind.path(), s.handler(&kind))
+	}
+	s.srv = &http.Server{Addr: addr, Handler: s.mux}
+	return s
+}
+
+// Register adds health checks to the server. Checks can be registered after the server has started.
+func (s *Server) Register(checks ...Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range checks {
+		r := newRunner(c, len(s.runners))
+		s.runners = append(s.runners, r)
+		if s.started && c.Interval > 0 {
+			go r.runEvery(c.Interval, s.stop)
+		}
+	}
+}
+
+// runs the checks of the kind passed in on each request, or all checks if kind is nil
+func (s *Server) handler(kind *Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		serve(w, s.runnersOf(kind), logging.OrDefault(s.logger))
+	}
+}
+
+func (s *Server) runnersOf(kind *Kind) []*runner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runners []*runner
+	for _, r := range s.runners {
+		if kind == nil || r.check.Kind == *kind {
+			runners = append(runners, r)
+		}
+	}
+	return runners
+}
+
+// Handle registers an additional handler on the server, e.g. to expose metrics alongside the health checks.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start listens on the server address and serves the health checks in the background. An error is returned if the
+// address cannot be listened on or the TLS files cannot be loaded.
+func (s *Server) Start() error {
+	if s.certFile != "" || s.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load healthcheck server certificate: %v", err)
+		}
+		s.srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("cannot start healthcheck server: %v", err)
+	}
+	s.listener = listener
+	logging.OrDefault(s.logger).Info(fmt.Sprintf("starting healthcheck server on %s", listener.Addr()))
+
+	s.mu.Lock()
+	s.started = true
+	for _, r := range s.runners {
+		if r.check.Interval > 0 {
+			go r.runEvery(r.check.Interval, s.stop)
+		}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		var err error
+		if s.srv.TLSConfig != nil {
+			err = s.srv.ServeTLS(listener, "", "")
+		} else {
+			err = s.srv.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.OrDefault(s.logger).Error("healthcheck server stopped", logging.KeyError, err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on, or the configured address if it has not been started.
+// This is useful when listening on port 0.
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Shutdown gracefully stops the server, waiting for in flight requests until the context is done. The checks running
+// in the background are stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return s.srv.Shutdown(ctx)
+}
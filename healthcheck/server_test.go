@@ -0,0 +1,251 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthcheck
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var okCheck = func() (name string, health Health) {
+	return "Check", Health{Healthy: true, Status: "Ok"}
+}
+
+func TestServer_shouldServeHealthChecksOnConfiguredAddress(t *testing.T) {
+	// given
+	s := NewServer("127.0.0.1:0", []HealthCheck{okCheck})
+
+	// when
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+
+	// then
+	code, body := get(t, "http://"+s.Addr()+"/")
+	assert.Equal(t, http.StatusOK, code)
+	assertCheck(t, decode(t, body), "Check", StateUp, "Ok")
+}
+
+func TestServer_shouldAllowMoreThanOneServerInTheSameProcess(t *testing.T) {
+	// given
+	first := NewServer("127.0.0.1:0", []HealthCheck{okCheck})
+	second := NewServer("127.0.0.1:0", nil)
+	second.Handle("/extra", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	// when
+	require.NoError(t, first.Start())
+	defer first.Shutdown(context.Background())
+	require.NoError(t, second.Start())
+	defer second.Shutdown(context.Background())
+
+	// then the extra handler is only registered on the second server
+	resp, err := http.Get("http://" + second.Addr() + "/extra")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+
+	resp, err = http.Get("http://" + first.Addr() + "/extra")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_shouldReturnError_whenAddressIsInUse(t *testing.T) {
+	// given an address that is already in use
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	// when
+	err = NewServer(l.Addr().String(), nil).Start()
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot start healthcheck server")
+}
+
+func TestServer_shouldNotServeRequests_afterShutdown(t *testing.T) {
+	// given
+	s := NewServer("127.0.0.1:0", []HealthCheck{okCheck})
+	require.NoError(t, s.Start())
+	addr := s.Addr()
+
+	// when
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	// then
+	_, err := http.Get("http://" + addr + "/")
+	assert.Error(t, err)
+}
+
+func TestServer_shouldServeHealthChecksOverTLS(t *testing.T) {
+	// given
+	certFile, keyFile := writeSelfSignedCert(t)
+	s := NewServer("127.0.0.1:0", []HealthCheck{okCheck}, WithTLS(certFile, keyFile))
+
+	// when
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+
+	// then
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := c.Get("https://" + s.Addr() + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotNil(t, resp.TLS)
+}
+
+func TestServer_shouldReturnError_whenTLSFilesCannotBeLoaded(t *testing.T) {
+	// given
+	s := NewServer("127.0.0.1:0", nil, WithTLS("missing.pem", "missing-key.pem"))
+
+	// when
+	err := s.Start()
+
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot load healthcheck server certificate")
+}
+
+// writes a self signed certificate and key for 127.0.0.1 to a temporary directory, returning the file paths
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "healthcheck"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "healthcheck")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestServer_shouldServeEachKindOfCheckOnItsOwnEndpoint(t *testing.T) {
+	// given
+	failing := func() (name string, health Health) {
+		return "Dependency", Health{Healthy: false, Status: "unreachable"}
+	}
+	s := NewServer("127.0.0.1:0", []HealthCheck{failing})
+	s.Register(Check{Kind: Liveness, Check: okCheck})
+	s.Register(Check{Kind: Startup, Check: NewGate("Started", Health{Healthy: true, Status: "started"}).Check})
+
+	// when
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+
+	// then
+	code, body := get(t, "http://"+s.Addr()+"/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, decode(t, body).Checks, 1)
+	assertCheck(t, decode(t, body), "Check", StateUp, "Ok")
+
+	code, body = get(t, "http://"+s.Addr()+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Len(t, decode(t, body).Checks, 1)
+	assertCheck(t, decode(t, body), "Dependency", StateDown, "unreachable")
+
+	code, body = get(t, "http://"+s.Addr()+"/startupz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, decode(t, body).Checks, 1)
+	assertCheck(t, decode(t, body), "Started", StateUp, "started")
+
+	code, body = get(t, "http://"+s.Addr()+"/")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Len(t, decode(t, body).Checks, 3)
+}
+
+func TestServer_shouldReportGateChanges(t *testing.T) {
+	// given
+	gate := NewGate("Ready", Health{Healthy: false, Status: "starting"})
+	s := NewServer("127.0.0.1:0", nil)
+	s.Register(Check{Kind: Readiness, Check: gate.Check})
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+	code, _ := get(t, "http://"+s.Addr()+"/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	// when
+	gate.Set(true, "ready")
+
+	// then
+	code, body := get(t, "http://"+s.Addr()+"/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assertCheck(t, decode(t, body), "Ready", StateUp, "ready")
+}
+
+func TestServer_shouldNotFailProbe_whenANonCriticalCheckIsDown(t *testing.T) {
+	// given
+	down := func() (name string, health Health) {
+		return "Optional", Health{Healthy: false, Status: "unreachable"}
+	}
+	s := NewServer("127.0.0.1:0", []HealthCheck{okCheck})
+	s.Register(Check{Kind: Readiness, Check: down, NonCritical: true})
+	require.NoError(t, s.Start())
+	defer s.Shutdown(context.Background())
+
+	// when
+	code, body := get(t, "http://"+s.Addr()+"/readyz")
+
+	// then
+	assert.Equal(t, http.StatusOK, code)
+	resp := decode(t, body)
+	assert.Equal(t, StateDegraded, resp.State)
+	assertCheck(t, resp, "Optional", StateDown, "unreachable")
+	assert.False(t, resp.Checks["Optional"].Critical)
+	assert.True(t, resp.Checks["Check"].Critical)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
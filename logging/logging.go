@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging is how the client, packs and health checks log. The Logger interface follows log/slog, taking a
+// message and alternating keys and values, so other loggers can be plugged in. Zerolog is the default.
+package logging
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// The keys of the fields logged by the client and packs.
+const (
+	KeyError     = "error"
+	KeyPack      = "pack"
+	KeyCommand   = "command"
+	KeyActionURL = "actionURL"
+	KeyEvent     = "event"
+)
+
+// Logger writes structured log entries. The keyvals are alternating keys and values, as with log/slog, e.g.
+// logger.Info("action completed", logging.KeyCommand, "createIssue"). Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+	// With returns a logger that adds the keyvals to every entry.
+	With(keyvals ...interface{}) Logger
+}
+
+// Nop discards every entry, e.g. to keep tests quiet.
+var Nop Logger = nop{}
+
+type nop struct{}
+
+func (nop) Debug(string, ...interface{}) {}
+func (nop) Info(string, ...interface{})  {}
+func (nop) Warn(string, ...interface{})  {}
+func (nop) Error(string, ...interface{}) {}
+func (n nop) With(...interface{}) Logger { return n }
+
+var (
+	mu            sync.RWMutex
+	defaultLogger Logger = Zerolog(nil)
+)
+
+// Default returns the logger used when none is set on the client, pack or health check server. It logs to the
+// global zerolog logger unless replaced with SetDefault.
+func Default() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the default logger, e.g. with Nop to keep tests quiet. A nil logger restores zerolog.
+func SetDefault(l Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil {
+		l = Zerolog(nil)
+	}
+	defaultLogger = l
+}
+
+// OrDefault returns l, or the default logger if l is nil.
+func OrDefault(l Logger) Logger {
+	if l == nil {
+		return Default()
+	}
+	return l
+}
+
+// Fatal logs the message as an error then exits, as zerolog's Fatal does.
+func Fatal(l Logger, msg string, keyvals ...interface{}) {
+	l.Error(msg, keyvals...)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context holding the logger, e.g. so command handlers can log with the action fields.
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger in the context, or the default logger if there is none.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return l
+	}
+	return Default()
+}
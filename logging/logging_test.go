@@ -0,0 +1,74 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestZerolog_ShouldWriteFieldsAndLevel(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+	logger := Zerolog(&zl).With(KeyPack, "JiraPack")
+
+	// when
+	logger.Error("could not complete action", KeyCommand, "createIssue", KeyError, errors.New("rejected"))
+
+	// then
+	assert.JSONEq(t, `{"level":"error","pack":"JiraPack","command":"createIssue","error":"rejected","message":"could not complete action"}`, buf.String())
+}
+
+func TestZerolog_With_ShouldNotShareFieldsBetweenLoggers(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+	parent := Zerolog(&zl).With(KeyPack, "JiraPack")
+
+	// when
+	parent.With(KeyCommand, "createIssue")
+	parent.With(KeyCommand, "closeIssue").Info("handled")
+
+	// then
+	assert.JSONEq(t, `{"level":"info","pack":"JiraPack","command":"closeIssue","message":"handled"}`, buf.String())
+}
+
+func TestSetDefault_ShouldReplaceTheDefaultLogger(t *testing.T) {
+	defer SetDefault(nil)
+
+	SetDefault(Nop)
+
+	assert.Equal(t, Nop, Default())
+	assert.Equal(t, Nop, OrDefault(nil))
+	assert.Equal(t, Nop, FromContext(context.Background()))
+}
+
+func TestFromContext_ShouldReturnTheLoggerInTheContext(t *testing.T) {
+	var buf bytes.Buffer
+	zl := zerolog.New(&buf)
+	logger := Zerolog(&zl)
+
+	ctx := ContextWithLogger(context.Background(), logger)
+
+	assert.Equal(t, logger, FromContext(ctx))
+	assert.Equal(t, logger, OrDefault(logger))
+}
@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Zerolog adapts a zerolog logger. If l is nil, the global zerolog logger is used, looked up on every entry so it
+// can be configured after the logger is created.
+func Zerolog(l *zerolog.Logger) Logger {
+	return zerologLogger{logger: l}
+}
+
+type zerologLogger struct {
+	logger  *zerolog.Logger
+	keyvals []interface{}
+}
+
+func (z zerologLogger) Debug(msg string, keyvals ...interface{}) {
+	z.log(zerolog.DebugLevel, msg, keyvals)
+}
+
+func (z zerologLogger) Info(msg string, keyvals ...interface{}) {
+	z.log(zerolog.InfoLevel, msg, keyvals)
+}
+
+func (z zerologLogger) Warn(msg string, keyvals ...interface{}) {
+	z.log(zerolog.WarnLevel, msg, keyvals)
+}
+
+func (z zerologLogger) Error(msg string, keyvals ...interface{}) {
+	z.log(zerolog.ErrorLevel, msg, keyvals)
+}
+
+func (z zerologLogger) With(keyvals ...interface{}) Logger {
+	// copied so loggers created from the same parent do not share fields
+	z.keyvals = append(append([]interface{}(nil), z.keyvals...), keyvals...)
+	return z
+}
+
+func (z zerologLogger) log(level zerolog.Level, msg string, keyvals []interface{}) {
+	l := z.logger
+	if l == nil {
+		l = &log.Logger
+	}
+	l.WithLevel(level).Fields(z.keyvals).Fields(keyvals).Msg(msg)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics records what the client and packs do. The Metrics interface is small so other backends can be
+// plugged in; NewPrometheus is the default, serving the metrics in the Prometheus text format.
+package metrics
+
+import "time"
+
+// The metrics recorded by the client and packs.
+const (
+	ClientRequests           = "flyte_client_requests_total"            // labels: endpoint, status
+	ClientRequestDuration    = "flyte_client_request_duration_seconds"  // labels: endpoint, status
+	ClientRetries            = "flyte_client_retries_total"             // labels: endpoint
+	ClientCircuitState       = "flyte_client_circuit_state"             // gauge: 0 closed, 1 half-open, 2 open
+	ClientCircuitTransitions = "flyte_client_circuit_transitions_total" // labels: state
+	ClientCircuitRejected    = "flyte_client_circuit_rejected_total"    // labels: endpoint
+	ClientEndpointSwitches   = "flyte_client_endpoint_switches_total"   // labels: target (primary or secondary)
+	PackActionsTaken         = "flyte_pack_actions_taken_total"         // labels: command
+	PackActionsCompleted     = "flyte_pack_actions_completed_total"     // labels: command
+	PackHandlerDuration      = "flyte_pack_handler_duration_seconds"    // labels: command
+	PackPanicsRecovered      = "flyte_pack_panics_recovered_total"      // labels: command
+	PackFatalEvents          = "flyte_pack_fatal_events_total"          // labels: command
+	PackEventsSent           = "flyte_pack_events_sent_total"           // labels: event
+	PackPollsIdle            = "flyte_pack_polls_idle_total"
+	PackPollErrors           = "flyte_pack_poll_errors_total"
+)
+
+// descriptions of the metrics, used for the Prometheus HELP lines
+var help = map[string]string{
+	ClientRequests:           "Requests made to flyte-api by endpoint and http status.",
+	ClientRequestDuration:    "Latency of requests made to flyte-api by endpoint and http status.",
+	ClientRetries:            "Requests to flyte-api sent again after a failed attempt, by endpoint.",
+	ClientCircuitState:       "State of the flyte-api circuit breaker: 0 closed, 1 half-open, 2 open.",
+	ClientCircuitTransitions: "Changes of the flyte-api circuit breaker state by the state changed to.",
+	ClientCircuitRejected:    "Requests to flyte-api failed fast by the open circuit breaker, by endpoint.",
+	ClientEndpointSwitches:   "Switches between flyte-api endpoints, by whether the primary or a secondary endpoint was switched to.",
+	PackActionsTaken:         "Actions taken from flyte-api by command.",
+	PackActionsCompleted:     "Actions completed successfully by command.",
+	PackHandlerDuration:      "Time spent in command handlers by command.",
+	PackPanicsRecovered:      "Command handler panics recovered by command.",
+	PackFatalEvents:          "FATAL events emitted by command.",
+	PackEventsSent:           "Events sent by the pack by event name.",
+	PackPollsIdle:            "Polls for actions that found none available.",
+	PackPollErrors:           "Polls for actions that failed.",
+}
+
+// Labels are the dimensions of a measurement, e.g. the command name.
+type Labels map[string]string
+
+// Metrics records measurements. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// IncCounter adds one to the counter with the labels.
+	IncCounter(name string, labels Labels)
+	// ObserveDuration records a duration in the histogram with the labels.
+	ObserveDuration(name string, labels Labels, d time.Duration)
+}
+
+// Nop discards every measurement.
+var Nop Metrics = nop{}
+
+type nop struct{}
+
+func (nop) IncCounter(string, Labels)                     {}
+func (nop) ObserveDuration(string, Labels, time.Duration) {}
+
+// Gauges is implemented by backends that record values that go up and down, such as the circuit breaker state.
+// Prometheus implements it; backends that do not only record the counters and histograms.
+type Gauges interface {
+	// SetGauge sets the gauge with the labels to the value.
+	SetGauge(name string, labels Labels, value float64)
+}
+
+// SetGauge sets the gauge if m implements Gauges, and does nothing otherwise.
+func SetGauge(m Metrics, name string, labels Labels, value float64) {
+	if g, ok := m.(Gauges); ok {
+		g.SetGauge(name, labels, value)
+	}
+}
+
+// OrNop returns m, or Nop if m is nil.
+func OrNop(m Metrics) Metrics {
+	if m == nil {
+		return Nop
+	}
+	return m
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the histogram buckets. They are the Prometheus client defaults.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus keeps the measurements in memory and serves them in the Prometheus text format. It is an http.Handler,
+// so can be served on the health check server at /metrics.
+type Prometheus struct {
+	buckets  []float64
+	mu       sync.Mutex
+	families map[string]*family // by metric name
+}
+
+// the series of a metric, by their formatted labels
+type family struct {
+	metricType string
+	series     map[string]*series
+}
+
+// a counter or gauge value, or histogram bucket counts, sum and count
+type series struct {
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewPrometheus creates an empty Prometheus metrics. Histograms use the buckets passed in, or DefaultBuckets if none.
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Prometheus{buckets: sorted, families: make(map[string]*family)}
+}
+
+func (p *Prometheus) IncCounter(name string, labels Labels) {
+	key := formatLabels(labels)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.series(name, "counter", key).value++
+}
+
+func (p *Prometheus) SetGauge(name string, labels Labels, value float64) {
+	key := formatLabels(labels)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.series(name, "gauge", key).value = value
+}
+
+func (p *Prometheus) ObserveDuration(name string, labels Labels, d time.Duration) {
+	key := formatLabels(labels)
+	seconds := d.Seconds()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.series(name, "histogram", key)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(p.buckets))
+	}
+	for i, upper := range p.buckets {
+		if seconds <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += seconds
+	s.count++
+}
+
+// returns the series, creating it if needed. Must be called with the lock held
+func (p *Prometheus) series(name, metricType, labels string) *series {
+	f := p.families[name]
+	if f == nil {
+		f = &family{metricType: metricType, series: make(map[string]*series)}
+		p.families[name] = f
+	}
+	s := f.series[labels]
+	if s == nil {
+		s = &series{}
+		f.series[labels] = s
+	}
+	return s
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(p.text())
+}
+
+// the metrics in the Prometheus text format, sorted by name and labels so the output is stable
+func (p *Prometheus) text() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		f := p.families[name]
+		if h, ok := help[name]; ok {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, h)
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.metricType)
+
+		keys := make([]string, 0, len(f.series))
+		for labels := range f.series {
+			keys = append(keys, labels)
+		}
+		sort.Strings(keys)
+		for _, labels := range keys {
+			s := f.series[labels]
+			if f.metricType != "histogram" {
+				fmt.Fprintf(&b, "%s%s %s\n", name, braces(labels), formatFloat(s.value))
+				continue
+			}
+			for i, upper := range p.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braces(join(labels, `le="`+formatFloat(upper)+`"`)), s.counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braces(join(labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, braces(labels), formatFloat(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, braces(labels), s.count)
+		}
+	}
+	return b.Bytes()
+}
+
+// formats the labels as name="value" pairs sorted by name, escaping the values
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func join(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrometheus_ShouldWriteCountersInTextFormat(t *testing.T) {
+	// given
+	p := NewPrometheus()
+	p.IncCounter(PackActionsTaken, Labels{"command": "createIssue"})
+	p.IncCounter(PackActionsTaken, Labels{"command": "createIssue"})
+	p.IncCounter(PackActionsTaken, Labels{"command": "closeIssue"})
+	p.IncCounter(PackPollsIdle, nil)
+
+	// when
+	text := string(p.text())
+
+	// then
+	assert.Equal(t, `# HELP flyte_pack_actions_taken_total Actions taken from flyte-api by command.
+# TYPE flyte_pack_actions_taken_total counter
+flyte_pack_actions_taken_total{command="closeIssue"} 1
+flyte_pack_actions_taken_total{command="createIssue"} 2
+# HELP flyte_pack_polls_idle_total Polls for actions that found none available.
+# TYPE flyte_pack_polls_idle_total counter
+flyte_pack_polls_idle_total 1
+`, text)
+}
+
+func TestPrometheus_ShouldWriteGaugesInTextFormat(t *testing.T) {
+	// given
+	p := NewPrometheus()
+	SetGauge(p, ClientCircuitState, nil, 2)
+	SetGauge(p, ClientCircuitState, nil, 1)
+
+	// when
+	text := string(p.text())
+
+	// then
+	assert.Equal(t, `# HELP flyte_client_circuit_state State of the flyte-api circuit breaker: 0 closed, 1 half-open, 2 open.
+# TYPE flyte_client_circuit_state gauge
+flyte_client_circuit_state 1
+`, text)
+}
+
+func TestSetGauge_ShouldIgnoreBackendsWithoutGauges(t *testing.T) {
+	assert.NotPanics(t, func() { SetGauge(Nop, ClientCircuitState, nil, 2) })
+}
+
+func TestPrometheus_ShouldWriteHistogramsInTextFormat(t *testing.T) {
+	// given
+	p := NewPrometheus(0.1, 1)
+	labels := Labels{"endpoint": "takeAction", "status": "200"}
+	p.ObserveDuration(ClientRequestDuration, labels, 50*time.Millisecond)
+	p.ObserveDuration(ClientRequestDuration, labels, 500*time.Millisecond)
+	p.ObserveDuration(ClientRequestDuration, labels, 2*time.Second)
+
+	// when
+	text := string(p.text())
+
+	// then
+	assert.Equal(t, `# HELP flyte_client_request_duration_seconds Latency of requests made to flyte-api by endpoint and http status.
+# TYPE flyte_client_request_duration_seconds histogram
+flyte_client_request_duration_seconds_bucket{endpoint="takeAction",status="200",le="0.1"} 1
+flyte_client_request_duration_seconds_bucket{endpoint="takeAction",status="200",le="1"} 2
+flyte_client_request_duration_seconds_bucket{endpoint="takeAction",status="200",le="+Inf"} 3
+flyte_client_request_duration_seconds_sum{endpoint="takeAction",status="200"} 2.55
+flyte_client_request_duration_seconds_count{endpoint="takeAction",status="200"} 3
+`, text)
+}
+
+func TestPrometheus_ShouldEscapeLabelValues(t *testing.T) {
+	p := NewPrometheus()
+	p.IncCounter("custom_total", Labels{"value": "a \"quoted\"\\path\nline"})
+
+	assert.Equal(t, "# TYPE custom_total counter\ncustom_total{value=\"a \\\"quoted\\\"\\\\path\\nline\"} 1\n", string(p.text()))
+}
+
+func TestPrometheus_ShouldServeMetrics(t *testing.T) {
+	// given
+	p := NewPrometheus()
+	p.IncCounter(PackPollErrors, nil)
+	responseWriter := httptest.NewRecorder()
+
+	// when
+	p.ServeHTTP(responseWriter, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", responseWriter.Header().Get("Content-Type"))
+	assert.Contains(t, responseWriter.Body.String(), "flyte_pack_poll_errors_total 1\n")
+}
+
+func TestOrNop_ShouldReturnNop_WhenNoMetricsAreSet(t *testing.T) {
+	assert.Equal(t, Nop, OrNop(nil))
+
+	p := NewPrometheus()
+	assert.Equal(t, p, OrNop(p))
+}
@@ -0,0 +1,42 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TraceParentHeader is the W3C trace context header, see https://www.w3.org/TR/trace-context/
+const TraceParentHeader = "traceparent"
+
+// Propagator propagates trace context to and from flyte-api, as W3C traceparent and tracestate headers.
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// Inject sets the trace context of the span in ctx on the carrier, e.g. a propagation.HeaderCarrier. It does nothing
+// if there is no span.
+func Inject(ctx context.Context, c propagation.TextMapCarrier) {
+	Propagator.Inject(ctx, c)
+}
+
+// Extract returns a context whose spans are children of the remote span in the trace context on the carrier, e.g. a
+// propagation.MapCarrier of action metadata. If the carrier has no valid trace context, ctx is returned unchanged.
+func Extract(ctx context.Context, c propagation.TextMapCarrier) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return Propagator.Extract(ctx, c)
+}
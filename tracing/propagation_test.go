@@ -0,0 +1,70 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"testing"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestInject_ShouldSetTheTraceParentOfTheSpanInTheContext(t *testing.T) {
+	// given
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+	header := http.Header{}
+
+	// when
+	Inject(ctx, propagation.HeaderCarrier(header))
+
+	// then
+	sc := span.SpanContext()
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", header.Get(TraceParentHeader))
+}
+
+func TestInject_ShouldNotSetTheTraceParent_WhenThereIsNoSpan(t *testing.T) {
+	header := http.Header{}
+
+	Inject(context.Background(), propagation.HeaderCarrier(header))
+
+	assert.Empty(t, header)
+}
+
+func TestExtract_ShouldReturnContextWithRemoteParent(t *testing.T) {
+	// when
+	ctx := Extract(context.Background(), propagation.MapCarrier{TraceParentHeader: traceParent})
+
+	// then
+	sc := trace.SpanContextFromContext(ctx)
+	require.True(t, sc.IsValid())
+	assert.True(t, sc.IsRemote())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+}
+
+func TestExtract_ShouldReturnContextUnchanged_WhenTraceParentIsMissingOrInvalid(t *testing.T) {
+	assert.False(t, trace.SpanContextFromContext(Extract(context.Background(), nil)).IsValid())
+	assert.False(t, trace.SpanContextFromContext(Extract(context.Background(), propagation.MapCarrier(nil))).IsValid())
+	assert.False(t, trace.SpanContextFromContext(Extract(context.Background(), propagation.MapCarrier{TraceParentHeader: "invalid"})).IsValid())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package tracing records OpenTelemetry spans for the work done by packs. Spans are started with a tracer of the
+// trace.TracerProvider set on the pack, or of the global provider when none is set, so the pack's spans are part of
+// the traces of the application. Trace context is propagated to and from flyte-api with W3C traceparent headers.
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer packs record spans with.
+const InstrumentationName = "github.com/ExpediaGroup/flyte-client"
+
+// The names of the spans recorded by packs.
+const (
+	SpanTakeAction     = "TakeAction"
+	SpanHandleAction   = "handleAction"
+	SpanCompleteAction = "CompleteAction"
+	SpanPostEvent      = "PostEvent"
+)
+
+// The attributes set on the spans recorded by packs.
+const (
+	AttributePack    = attribute.Key("flyte.pack")
+	AttributeCommand = attribute.Key("flyte.command")
+	AttributeEvent   = attribute.Key("flyte.event")
+)
+
+// Tracer returns the tracer packs record spans with, from tp or, if tp is nil, from the global provider set with
+// otel.SetTracerProvider. Spans are not recorded when neither is set.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(InstrumentationName)
+}
+
+// RecordError records err on the span and marks it as failed. It does nothing if err is nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// SpanFromContext returns the span in the context, started by a pack or by the application. If there is none, a span
+// that records nothing is returned.
+func SpanFromContext(ctx context.Context) trace.Span {
+	return trace.SpanFromContext(ctx)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestTracer_ShouldUseTheProviderSet(t *testing.T) {
+	// given
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	// when
+	_, span := Tracer(tp).Start(context.Background(), "op")
+	span.End()
+
+	// then
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, InstrumentationName, spans[0].InstrumentationLibrary.Name)
+}
+
+func TestTracer_ShouldUseTheGlobalProvider_WhenNoneIsSet(t *testing.T) {
+	// given
+	exporter := tracetest.NewInMemoryExporter()
+	global := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(global)
+
+	// when
+	_, span := Tracer(nil).Start(context.Background(), "op")
+	span.End()
+
+	// then
+	assert.Len(t, exporter.GetSpans(), 1)
+}
+
+func TestRecordError_ShouldMarkTheSpanAsFailed(t *testing.T) {
+	// given
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := Tracer(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	// when
+	_, failed := tracer.Start(context.Background(), "failed")
+	RecordError(failed, errors.New("rejected"))
+	failed.End()
+	_, succeeded := tracer.Start(context.Background(), "succeeded")
+	RecordError(succeeded, nil)
+	succeeded.End()
+
+	// then
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "rejected", spans[0].Status.Description)
+	require.Len(t, spans[0].Events, 1)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	assert.Empty(t, spans[1].Events)
+}